@@ -10,15 +10,20 @@ import (
 	"os/signal"
 	"path"
 	"path/filepath"
+	"reflect"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 	"time"
 
+	"kubectlfzf/pkg/graph"
 	"kubectlfzf/pkg/k8sresources"
 	"kubectlfzf/pkg/resourcewatcher"
 	"kubectlfzf/pkg/util"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/golang/glog"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -40,13 +45,23 @@ var (
 	inCluster              bool
 	kubeconfig             string
 	excludedResources      []string
+	metadataOnlyResources  []string
 	excludedNamespaces     []string
 	cacheDir               string
 	roleBlacklist          []string
 	roleBlacklistSet       map[string]bool
+	hideSecretKeys         bool
 	timeBetweenFullDump    time.Duration
 	nodePollingPeriod      time.Duration
 	namespacePollingPeriod time.Duration
+	listPageSize           int64
+	resumeWatches          bool
+
+	graphNamespace string
+	graphRoot      string
+	graphFormat    string
+
+	statusCluster string
 
 	daemonCmd         string
 	daemonName        string
@@ -54,6 +69,9 @@ var (
 	daemonLogFilePath string
 )
 
+// inClusterNamespaceFile holds the namespace of the pod when running in cluster
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 func init() {
 	if home := os.Getenv("HOME"); home != "" {
 		flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
@@ -70,13 +88,17 @@ func init() {
 	flag.Bool("cpu-profile", false, "Start with cpu profiling")
 	flag.Bool("in-cluster", false, "Use in-cluster configuration")
 	flag.String("excluded-namespaces", "", "Namespaces to exclude, separated by space")
-	flag.String("excluded-resources", "", "Resources to exclude, separated by space. To exclude everything: pods configmaps services serviceaccounts replicasets daemonsets secrets statefulsets deployments endpoints ingresses cronjobs jobs horizontalpodautoscalers persistentvolumes persistentvolumeclaims nodes namespaces")
+	flag.String("excluded-resources", "", "Resources to exclude, separated by space. To exclude everything: pods containers configmaps services serviceaccounts replicasets daemonsets secrets helmreleases statefulsets deployments endpoints ingresses cronjobs jobs horizontalpodautoscalers persistentvolumes persistentvolumeclaims nodes namespaces")
+	flag.String("metadata-only-resources", "", "Resources to watch with their metadata only to reduce memory, separated by space. Supported: configmaps secrets. Their keys, secret types and data counts are unknown and helm releases are built from labels")
 	flag.String("cluster-name", "incluster", "The cluster name. Needed for cross-cluster completion.")
 	flag.String("cache-dir", defaultCacheDirEnv, "Cache dir location. Default to KUBECTL_FZF_CACHE env var")
 	flag.String("role-blacklist", "", "List of roles to hide from node list, separated by commas")
+	flag.Bool("hide-secret-keys", false, "Don't display the key names of secrets. Secret values are never read")
 	flag.Duration("time-between-fulldump", 60*time.Second, "Buffer changes and only do full dump every x secondes")
 	flag.Duration("node-polling-period", 300*time.Second, "Polling period for nodes")
 	flag.Duration("namespace-polling-period", 600*time.Second, "Polling period for namespaces")
+	flag.Int64("list-page-size", 500, "Number of objects fetched per request when listing resources, written as they arrive. 0 lists everything in one request")
+	flag.Bool("resume-watches", true, "Journal watched objects in the cache dir to resume the watches from their last resource version on restart instead of listing everything")
 
 	flag.String("daemon", "", `Send signal to the daemon:
   start - run as a daemon
@@ -89,6 +111,8 @@ func init() {
 	flag.String("daemon-log-file", defaultLogPath, "Daemon's log file path")
 
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	// Flags after a subcommand are its own options
+	pflag.CommandLine.SetInterspersed(false)
 	pflag.Parse()
 	viper.AutomaticEnv()
 	viper.BindPFlags(pflag.CommandLine)
@@ -97,8 +121,8 @@ func init() {
 	viper.AddConfigPath("/etc/kubectl_fzf/")
 	viper.AddConfigPath("$HOME")
 	err := viper.ReadInConfig()
-	if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-		util.FatalIf(err)
+	if _, ok := err.(viper.ConfigFileNotFoundError); err != nil && !ok {
+		glog.Exitf("Error reading configuration: %v", err)
 	}
 
 	displayVersion = viper.GetBool("version")
@@ -106,13 +130,9 @@ func init() {
 	inCluster = viper.GetBool("in-cluster")
 	kubeconfig = viper.GetString("kubeconfig")
 	cacheDir = viper.GetString("cache-dir")
-	roleBlacklist = viper.GetStringSlice("role-blacklist")
 	clusterName = viper.GetString("cluster-name")
-	excludedNamespaces = viper.GetStringSlice("excluded-namespaces")
-	excludedResources = viper.GetStringSlice("excluded-resources")
-	timeBetweenFullDump = viper.GetDuration("time-between-fulldump")
-	nodePollingPeriod = viper.GetDuration("node-polling-period")
-	namespacePollingPeriod = viper.GetDuration("namespace-polling-period")
+	readReloadableSettings().apply()
+	readRestartSettings().apply()
 
 	daemonCmd = viper.GetString("daemon")
 	daemonName = viper.GetString("daemon-name")
@@ -120,6 +140,88 @@ func init() {
 	daemonLogFilePath = viper.GetString("daemon-log-file")
 }
 
+// reloadableSettings are the settings applied to the running watches when the configuration file changes
+type reloadableSettings struct {
+	roleBlacklist          []string
+	excludedNamespaces     []string
+	excludedResources      []string
+	timeBetweenFullDump    time.Duration
+	nodePollingPeriod      time.Duration
+	namespacePollingPeriod time.Duration
+}
+
+func readReloadableSettings() reloadableSettings {
+	return reloadableSettings{
+		roleBlacklist:          viper.GetStringSlice("role-blacklist"),
+		excludedNamespaces:     viper.GetStringSlice("excluded-namespaces"),
+		excludedResources:      viper.GetStringSlice("excluded-resources"),
+		timeBetweenFullDump:    viper.GetDuration("time-between-fulldump"),
+		nodePollingPeriod:      viper.GetDuration("node-polling-period"),
+		namespacePollingPeriod: viper.GetDuration("namespace-polling-period"),
+	}
+}
+
+func (s reloadableSettings) apply() {
+	roleBlacklist = s.roleBlacklist
+	excludedNamespaces = s.excludedNamespaces
+	excludedResources = s.excludedResources
+	timeBetweenFullDump = s.timeBetweenFullDump
+	nodePollingPeriod = s.nodePollingPeriod
+	namespacePollingPeriod = s.namespacePollingPeriod
+}
+
+// restartSettings are the settings applied by restarting the watch of the cluster when the configuration file changes
+type restartSettings struct {
+	listPageSize          int64
+	resumeWatches         bool
+	metadataOnlyResources []string
+	hideSecretKeys        bool
+}
+
+func readRestartSettings() restartSettings {
+	return restartSettings{
+		listPageSize:          viper.GetInt64("list-page-size"),
+		resumeWatches:         viper.GetBool("resume-watches"),
+		metadataOnlyResources: viper.GetStringSlice("metadata-only-resources"),
+		hideSecretKeys:        viper.GetBool("hide-secret-keys"),
+	}
+}
+
+func (s restartSettings) apply() {
+	listPageSize = s.listPageSize
+	resumeWatches = s.resumeWatches
+	metadataOnlyResources = s.metadataOnlyResources
+	hideSecretKeys = s.hideSecretKeys
+}
+
+// configSettings are the settings read from a changed configuration file
+type configSettings struct {
+	reloadable reloadableSettings
+	restart    restartSettings
+}
+
+// watchConfigFile sends the settings each time the configuration file changes.
+// Settings are read from the watching goroutine as viper isn't safe for concurrent use
+func watchConfigFile() <-chan configSettings {
+	changes := make(chan configSettings, 1)
+	if viper.ConfigFileUsed() == "" {
+		glog.Infof("No configuration file, configuration changes won't be applied until restart")
+		return changes
+	}
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		glog.Infof("Configuration file %s changed", e.Name)
+		settings := configSettings{readReloadableSettings(), readRestartSettings()}
+		// Only the last settings are kept if the previous ones weren't applied yet
+		select {
+		case <-changes:
+		default:
+		}
+		changes <- settings
+	})
+	viper.WatchConfig()
+	return changes
+}
+
 func handleSignals(cancel context.CancelFunc) {
 	sigIn := make(chan os.Signal, 100)
 	signal.Notify(sigIn)
@@ -137,7 +239,9 @@ func termHandler(sig os.Signal) error {
 	return daemon.ErrStop
 }
 
-func startWatchOnCluster(ctx context.Context, config *restclient.Config, inCluster bool, cluster string) resourcewatcher.ResourceWatcher {
+// startWatchOnCluster starts the watch of all resources of a cluster.
+// Resources failing to start are marked as degraded while the others keep running
+func startWatchOnCluster(ctx context.Context, config *restclient.Config, inCluster bool, cluster string) (resourcewatcher.ResourceWatcher, error) {
 	clusterDir := cluster
 	if inCluster {
 		clusterDir = "incluster"
@@ -146,44 +250,111 @@ func startWatchOnCluster(ctx context.Context, config *restclient.Config, inClust
 		CacheDir:            cacheDir,
 		ClusterDir:          clusterDir,
 		TimeBetweenFullDump: timeBetweenFullDump,
+		ResumeWatches:       resumeWatches,
 	}
-	watcher := resourcewatcher.NewResourceWatcher(config, storeConfig, excludedNamespaces)
-	watcher.FetchNamespaces(ctx)
-	watchConfigs := watcher.GetWatchConfigs(nodePollingPeriod, namespacePollingPeriod, excludedResources)
-	ctorConfig := k8sresources.CtorConfig{
-		RoleBlacklist: roleBlacklistSet,
-		Cluster:       cluster,
+	watcher, err := resourcewatcher.NewResourceWatcher(config, storeConfig, excludedNamespaces, listPageSize)
+	if err != nil {
+		return watcher, err
+	}
+	err = watcher.FetchNamespaces(ctx, getDefaultNamespace())
+	if err != nil {
+		glog.Warningf("Namespaced resources will be watched across all namespaces or in the default namespace: %v", err)
 	}
+	watchConfigs := watcher.GetWatchConfigs(nodePollingPeriod, namespacePollingPeriod, excludedResources, metadataOnlyResources)
+	ctorConfig := getCtorConfig(cluster)
 
 	glog.Infof("Start cache build on cluster %s", cluster)
 	for _, watchConfig := range watchConfigs {
 		err := watcher.Start(ctx, watchConfig, ctorConfig)
-		util.FatalIf(err)
+		if err != nil {
+			glog.Warningf("Resource degraded: %v", err)
+		}
+	}
+	err = watcher.DumpAPIResources()
+	if err != nil {
+		glog.Warningf("Error dumping api resources: %v", err)
 	}
-	err := watcher.DumpAPIResources()
-	util.FatalIf(err)
-	return watcher
+	watcher.StartStatusDump(ctx)
+	return watcher, nil
+}
+
+func getCtorConfig(cluster string) k8sresources.CtorConfig {
+	return k8sresources.CtorConfig{
+		RoleBlacklist:  roleBlacklistSet,
+		Cluster:        cluster,
+		HideSecretKeys: hideSecretKeys,
+	}
+}
+
+// restartWatch starts again the stopped watch of the current cluster with the new settings.
+// It returns true if the start needs to be retried
+func restartWatch(ctx context.Context, watcher *resourcewatcher.ResourceWatcher, restConfig *restclient.Config, cluster string) bool {
+	newWatcher, err := startWatchOnCluster(ctx, restConfig, inCluster, cluster)
+	if err != nil {
+		glog.Warningf("Error restarting watch on cluster %s: %v", cluster, err)
+		return true
+	}
+	*watcher = newWatcher
+	return false
+}
+
+// reconfigureWatch applies the reloaded settings to the watch of the current cluster
+func reconfigureWatch(ctx context.Context, watcher *resourcewatcher.ResourceWatcher, cluster string, settings reloadableSettings) error {
+	settings.apply()
+	processArgs()
+	watchConfigs := watcher.GetWatchConfigs(nodePollingPeriod, namespacePollingPeriod, excludedResources, metadataOnlyResources)
+	return watcher.Reconfigure(ctx, watchConfigs, getCtorConfig(cluster), excludedNamespaces, timeBetweenFullDump)
 }
 
-func getClientConfigAndCluster() (*rest.Config, string) {
+func getClientConfigAndCluster() (*rest.Config, string, error) {
 	if inCluster {
 		restConfig, err := rest.InClusterConfig()
-		util.FatalIf(err)
-		return restConfig, clusterName
+		if err != nil {
+			return nil, "", errors.Wrap(err, "Error loading in-cluster configuration")
+		}
+		return restConfig, clusterName, nil
 	}
 
 	configInBytes, err := ioutil.ReadFile(kubeconfig)
-	util.FatalIf(err)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Error reading kubeconfig %s", kubeconfig)
+	}
 	clientConfig, err := clientcmd.NewClientConfigFromBytes(configInBytes)
-	util.FatalIf(err)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Error parsing kubeconfig %s", kubeconfig)
+	}
 
 	rawConfig, err := clientConfig.RawConfig()
-	util.FatalIf(err)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Error parsing kubeconfig %s", kubeconfig)
+	}
 	cluster := rawConfig.CurrentContext
 
 	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	util.FatalIf(err)
-	return cfg, cluster
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Error building configuration of %s", cluster)
+	}
+	return cfg, cluster, nil
+}
+
+// getDefaultNamespace returns the namespace of the current context, checked when namespaces can't be listed
+func getDefaultNamespace() string {
+	if inCluster {
+		namespace, err := ioutil.ReadFile(inClusterNamespaceFile)
+		if err != nil {
+			glog.Warningf("Error reading pod namespace: %v", err)
+			return ""
+		}
+		return strings.TrimSpace(string(namespace))
+	}
+	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		glog.Warningf("Error reading namespace of the current context: %v", err)
+		return ""
+	}
+	return namespace
 }
 
 func processArgs() {
@@ -191,7 +362,81 @@ func processArgs() {
 	roleBlacklistSet = util.StringSliceToSet(roleBlacklist)
 }
 
-func start() {
+// parseGraphFlags parses the options of the graph subcommand.
+// Subcommand options are not read from the configuration file
+func parseGraphFlags(args []string) error {
+	flags := pflag.NewFlagSet("graph", pflag.ContinueOnError)
+	flags.StringVar(&graphNamespace, "namespace", "", "Only keep relations involving this namespace. Default namespace of --root")
+	flags.StringVar(&graphRoot, "root", "", "Only keep objects related to this object, as resource/name or resource/namespace/name")
+	flags.StringVar(&graphFormat, "format", "dot", "Output format, dot or json")
+	return flags.Parse(args)
+}
+
+// parseStatusFlags parses the options of the status subcommand
+func parseStatusFlags(args []string) error {
+	flags := pflag.NewFlagSet("status", pflag.ContinueOnError)
+	flags.StringVar(&statusCluster, "cluster", "", "Cluster cache dir to show. Default to the current context")
+	return flags.Parse(args)
+}
+
+// getCurrentClusterDir returns the name of the cache dir of the current cluster
+func getCurrentClusterDir() (string, error) {
+	if inCluster {
+		return "incluster", nil
+	}
+	_, cluster, err := getClientConfigAndCluster()
+	return cluster, err
+}
+
+// printGraph writes the object graph built from the cache of the current cluster
+func printGraph() error {
+	cluster, err := getCurrentClusterDir()
+	if err != nil {
+		return err
+	}
+	g, err := graph.LoadGraph(path.Join(cacheDir, cluster))
+	if err != nil {
+		return err
+	}
+	if graphRoot != "" {
+		root, err := graph.ParseRoot(graphRoot, graphNamespace)
+		if err != nil {
+			return err
+		}
+		g, err = g.Related(root)
+		if err != nil {
+			return err
+		}
+	} else if graphNamespace != "" {
+		g = g.FilterNamespace(graphNamespace)
+	}
+	switch graphFormat {
+	case "dot":
+		return g.WriteDOT(os.Stdout, cluster)
+	case "json":
+		return g.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("Unknown graph format %s, expected dot or json", graphFormat)
+}
+
+// printStatus writes the status of the cache of a cluster as a table
+func printStatus() error {
+	cluster := statusCluster
+	if cluster == "" {
+		var err error
+		cluster, err = getCurrentClusterDir()
+		if err != nil {
+			return err
+		}
+	}
+	status, err := resourcewatcher.ReadClusterStatus(path.Join(cacheDir, cluster))
+	if err != nil {
+		return err
+	}
+	return status.WriteTable(os.Stdout)
+}
+
+func start() error {
 	if displayVersion {
 		fmt.Printf("Version: %s\n", Version)
 		if GitCommit != "" {
@@ -206,12 +451,14 @@ func start() {
 		if GoVersion != "" {
 			fmt.Printf("Go Version: %s\n", GoVersion)
 		}
-		os.Exit(0)
+		return nil
 	}
 
 	if cpuProfile {
 		f, err := os.Create("cpu.pprof")
-		util.FatalIf(err)
+		if err != nil {
+			return errors.Wrap(err, "Error creating cpu profile")
+		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
@@ -219,23 +466,66 @@ func start() {
 	ctx, cancel := context.WithCancel(context.Background())
 	go handleSignals(cancel)
 
-	currentRestConfig, currentCluster := getClientConfigAndCluster()
-	watcher := startWatchOnCluster(ctx, currentRestConfig, inCluster, currentCluster)
+	currentRestConfig, currentCluster, err := getClientConfigAndCluster()
+	if err != nil {
+		return err
+	}
+	watcher, err := startWatchOnCluster(ctx, currentRestConfig, inCluster, currentCluster)
+	if err != nil {
+		return err
+	}
 	ticker := time.NewTicker(time.Second * 5)
+	restart := readRestartSettings()
+	configChanges := watchConfigFile()
+	restartPending := false
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
+		case settings := <-configChanges:
+			if !restartPending && reflect.DeepEqual(settings.restart, restart) {
+				err := reconfigureWatch(ctx, &watcher, currentCluster, settings.reloadable)
+				if err != nil {
+					// The running watches keep their configuration until the file is fixed
+					glog.Warningf("Error applying configuration: %v", err)
+				}
+				continue
+			}
+			// Settings used when the resources are created need a new watcher
+			if !restartPending {
+				glog.Infof("Restarting watch of cluster %s to apply the configuration", currentCluster)
+				watcher.Stop()
+			}
+			settings.reloadable.apply()
+			settings.restart.apply()
+			processArgs()
+			restart = settings.restart
+			restartPending = restartWatch(ctx, &watcher, currentRestConfig, currentCluster)
 		case <-ticker.C:
-			restConfig, cluster := getClientConfigAndCluster()
+			if restartPending {
+				restartPending = restartWatch(ctx, &watcher, currentRestConfig, currentCluster)
+			}
+			restConfig, cluster, err := getClientConfigAndCluster()
+			if err != nil {
+				// Keep watching the current cluster until the configuration is fixed
+				glog.Warningf("Error checking configuration: %v", err)
+				continue
+			}
 			glog.V(7).Infof("Checking config %s %s ", restConfig.Host, currentRestConfig.Host)
 			if restConfig.Host != currentRestConfig.Host {
 				glog.Infof("Detected cluster change %s != %s", restConfig.Host, currentRestConfig.Host)
 				watcher.Stop()
-				watcher = startWatchOnCluster(ctx, restConfig, inCluster, cluster)
+				newWatcher, err := startWatchOnCluster(ctx, restConfig, inCluster, cluster)
+				if err != nil {
+					// The current config is kept so the start is retried on the next check
+					glog.Warningf("Error starting watch on cluster %s: %v", cluster, err)
+					continue
+				}
+				watcher = newWatcher
 				currentRestConfig = restConfig
 				currentCluster = cluster
+				restartPending = false
 			}
 		}
 	}
@@ -246,8 +536,34 @@ func main() {
 	flag.Parse()
 	processArgs()
 
+	switch pflag.Arg(0) {
+	case "graph":
+		err := parseGraphFlags(pflag.Args()[1:])
+		if err != nil {
+			glog.Exit(err)
+		}
+		err = printGraph()
+		if err != nil {
+			glog.Exit(err)
+		}
+		return
+	case "status":
+		err := parseStatusFlags(pflag.Args()[1:])
+		if err != nil {
+			glog.Exit(err)
+		}
+		err = printStatus()
+		if err != nil {
+			glog.Exit(err)
+		}
+		return
+	}
+
 	if daemonCmd == "" && !daemon.WasReborn() {
-		start()
+		err := start()
+		if err != nil {
+			glog.Exit(err)
+		}
 		return
 	}
 
@@ -286,7 +602,12 @@ func main() {
 	glog.Infoln("- - - - - - - - - - - - - - -")
 	glog.Infoln("daemon started")
 
-	go start()
+	go func() {
+		err := start()
+		if err != nil {
+			glog.Exit(err)
+		}
+	}()
 
 	err = daemon.ServeSignals()
 	if err != nil {
@@ -3,7 +3,7 @@ set -eu
 
 . kubectl_fzf.sh
 
-resources=("pods" "serviceaccounts" "daemonsets" "replicasets" "cronjobs" "horizontalpodautoscalers" "ingresses" "configmaps" "secrets" "namespaces" "nodes" "deployments" "statefulsets" "persistentvolumes" "persistentvolumeclaims" "endpoints" "services")
+resources=("pods" "containers" "serviceaccounts" "daemonsets" "replicasets" "cronjobs" "horizontalpodautoscalers" "ingresses" "configmaps" "secrets" "helmreleases" "namespaces" "nodes" "deployments" "statefulsets" "persistentvolumes" "persistentvolumeclaims" "endpoints" "services")
 
 while true; do
     current_context=$(kubectl config current-context)
@@ -4,6 +4,7 @@ eval "`declare -f __kubectl_parse_resource | sed '1s/.*/_&/'`"
 eval "`declare -f __kubectl_get_containers | sed '1s/.*/_&/'`"
 eval "`declare -f __kubectl_get_resource | sed '1s/.*/_&/'`"
 eval "`declare -f __kubectl_handle_filename_extension_flag | sed '1s/.*/_&/'`"
+declare -f __helm_handle_go_custom_completion > /dev/null && eval "`declare -f __helm_handle_go_custom_completion | sed '1s/.*/_&/'`"
 KUBECTL_FZF_CONF=${KUBECTL_FZF_CONF:-$HOME/.kubectl_fzf.sh}
 KUBECTL_FZF_EXCLUDE=${KUBECTL_FZF_EXCLUDE:-}
 KUBECTL_FZF_OPTIONS=(-1 --header-lines=2 --layout reverse -e --no-hscroll --no-sort)
@@ -268,6 +269,74 @@ _fzf_get_main_header()
     echo $main_header
 }
 
+# $1 is context
+# $2 is resource_name
+# Shows the namespaces watched by cache_builder when the resource can't be listed cluster-wide
+_fzf_get_access_header()
+{
+    local access_file=$(_fzf_get_filepath $1 $2 "_access")
+    if [[ ! -f $access_file ]]; then
+        return
+    fi
+    local access=$(cut -d ' ' -f 1 "$access_file")
+    if [[ $access == "namespaces" ]]; then
+        echo ", Only namespaces:$(cut -d ' ' -f 2 "$access_file")"
+    elif [[ $access == "forbidden" ]]; then
+        echo ", Forbidden:$2"
+    fi
+}
+
+# $1 is context
+# $2 is resource_name
+# Shows when the cache is a snapshot of a previous cache_builder run, displayed until the resource is synced
+_fzf_get_stale_header()
+{
+    local stale_file=$(_fzf_get_filepath $1 $2 "_stale")
+    if [[ -f $stale_file ]]; then
+        echo ", Stale snapshot from:$(cat "$stale_file")"
+    fi
+}
+
+# $@ are resource or relation files
+# Resource and relation files are logs of "= key line", "+ key line" and "- key" records, compacted by cache_builder.
+# "=" replaces the lines of the key, "+" adds a line to the key and "-" deletes the key.
+# Prints the current lines, lines without record are printed as is
+_fzf_read_resource_files()
+{
+    awk '
+        ($1 == "=" || $1 == "+") {
+            line = $0
+            sub(/^[=+] [^ ]+ /, "", line)
+            key = FILENAME " " $2
+            if (!(key in lines)) {
+                order[n++] = key
+                lines[key] = line
+            } else if ($1 == "=") {
+                lines[key] = line
+            } else {
+                lines[key] = lines[key] "\n" line
+            }
+            next
+        }
+        ($1 == "-") {
+            delete lines[FILENAME " " $2]
+            next
+        }
+        {
+            order[n++] = FILENAME " " FNR
+            lines[FILENAME " " FNR] = $0
+        }
+        END {
+            for (i = 0; i < n; i++) {
+                key = order[i]
+                if ((key in lines) && !(key in printed)) {
+                    printed[key] = 1
+                    print lines[key]
+                }
+            }
+        }' "$@"
+}
+
 _fzf_get_exclude_pattern()
 {
     local grep_exclude=""
@@ -303,7 +372,7 @@ _fzf_get_node_to_pods()
         fi
     done
 
-    grep -v $exclude_pods $pod_file \
+    _fzf_read_resource_files $pod_file | grep -v $exclude_pods \
         | awk "{ if(a[\$$node_name_field]==\"\") {a[\$$node_name_field]=\$$pod_name_field} else { a[\$$node_name_field]=\$$pod_name_field \":\" a[\$$node_name_field] } } END { for (i in a) { print i \" \"  substr(a[i], 0, 1800) } } "
 }
 
@@ -334,9 +403,10 @@ _fzf_kubectl_pv_complete()
     local pod_name_field=$(_fzf_get_header_position $pod_header_file "Name")
     local pod_namespace_field=$(_fzf_get_header_position $pod_header_file "Namespace")
 
-    local data=$(join -a1 -o'1.1,1.2,1.3,1.4,1.5,1.6,1.7,1.8,2.2' -1 $claim_field_pv_file -2 1 -e None \
-        <(cut -d ' ' -f 1-$end_field "$pv_resource_files" | sort -k $claim_field_pv_file) \
-        <(awk "(\$$claim_field_pod_file != \"None\"){split(\$$claim_field_pod_file,c,\",\"); for (i in c) { print c[i] \" \" \$$pod_namespace_field\"/\"\$$pod_name_field } }" $pod_files | sort))
+    local join_fields=$(seq -s ',' -f '1.%g' 1 $end_field),2.2
+    local data=$(join -a1 -o"$join_fields" -1 $claim_field_pv_file -2 1 -e None \
+        <(_fzf_read_resource_files "$pv_resource_files" | cut -d ' ' -f 1-$end_field | sort -k $claim_field_pv_file) \
+        <(_fzf_read_resource_files $pod_files | awk "(\$$claim_field_pod_file != \"None\"){split(\$$claim_field_pod_file,c,\",\"); for (i in c) { print c[i] \" \" \$$pod_namespace_field\"/\"\$$pod_name_field } }" | sort))
     local num_fields=$(echo $header | wc -w | sed 's/  *//g')
 
     KUBECTL_FZF_PREVIEW_OPTIONS=(--preview-window=down:$num_fields --preview "echo -e \"${header}\n{}\" | sed -e \"s/'//g\" | awk '(NR==1){for (i=1; i<=NF; i++) a[i]=\$i} (NR==2){for (i in a) {printf a[i] \": \" \$i \"\n\"} }' | column -t | fold -w \$COLUMNS" )
@@ -362,7 +432,7 @@ _fzf_kubectl_node_complete()
     header="$header Pods"
 
     local node_to_pods=$(_fzf_get_node_to_pods $context)
-    local data=$(join -a1 -oauto -e None <(cut -d ' ' -f 1-$end_field "$node_resource_file") <(echo "$node_to_pods"))
+    local data=$(join -a1 -oauto -e None <(_fzf_read_resource_files "$node_resource_file" | cut -d ' ' -f 1-$end_field) <(echo "$node_to_pods"))
     local num_fields=$(echo $header | wc -w | sed 's/  *//g')
     KUBECTL_FZF_PREVIEW_OPTIONS=(--preview-window=down:$num_fields --preview "echo -e \"${header}\n{}\" | sed -e \"s/'//g\" | awk '(NR==1){for (i=1; i<=NF; i++) a[i]=\$i} (NR==2){for (i in a) {printf a[i] \": \" \$i \"\n\"} }' | column -t | fold -w \$COLUMNS" )
     (printf "${main_header}\n"; printf "${header}\n${data}\n" | column -t) \
@@ -393,6 +463,7 @@ _fzf_kubectl_complete()
     local label_field=$(_fzf_get_header_position $header_file "Labels")
     local end_field=$((label_field - 1))
     local main_header=$(_fzf_get_main_header $current_context $current_context $namespace)
+    main_header="$main_header$(_fzf_get_access_header $current_context $resource_name)$(_fzf_get_stale_header $current_context $resource_name)"
 
     if [[ $is_flag == "with_namespace" ]]; then
         local header="Cluster Namespace Labels Occurrences"
@@ -402,7 +473,7 @@ _fzf_kubectl_complete()
         local data=$(cat ${label_files[@]})
     else
         local header=$(cut -d ' ' -f 1-$end_field "$header_file")
-        local data=$(cut -d ' ' -f 1-$end_field ${resource_files[@]})
+        local data=$(_fzf_read_resource_files ${resource_files[@]} | cut -d ' ' -f 1-$end_field)
     fi
 
     if [[ -n $namespace ]]; then
@@ -415,7 +486,14 @@ _fzf_kubectl_complete()
     fi
     local num_fields=$(echo $header | wc -w | sed 's/  *//g')
 
-    KUBECTL_FZF_PREVIEW_OPTIONS=(--preview-window=down:$num_fields --preview "echo -e \"${header}\n{}\" | sed -e \"s/'//g\" | awk '(NR==1){for (i=1; i<=NF; i++) a[i]=\$i} (NR==2){for (i in a) {printf a[i] \": \" \$i \"\n\"} }' | column -t | fold -w \$COLUMNS" )
+    local preview="echo -e \"${header}\n{}\" | sed -e \"s/'//g\" | awk '(NR==1){for (i=1; i<=NF; i++) a[i]=\$i} (NR==2){for (i in a) {printf a[i] \": \" \$i \"\n\"} }' | column -t | fold -w \$COLUMNS"
+    local relation_files=($(_fzf_get_filepaths $contexts $resource_name "_relations"))
+    if [[ $is_flag == "false" ]] && ls ${relation_files[@]} > /dev/null 2>&1; then
+        # Relation files are record files like resource files, the preview shell gets the reader's definition.
+        # Relation lines are "Cluster Namespace Name Relation Resource TargetNamespace TargetName Details", namespace is None for cluster resources
+        preview="$preview; $(declare -f _fzf_read_resource_files); _fzf_read_resource_files ${relation_files[@]} 2> /dev/null | awk -v c={1} -v n={2} -v r={3} '(\$1 == c && ((\$2 == n && \$3 == r) || (\$2 == \"None\" && \$3 == n))){print \$4 \": \" \$5 \"/\" \$7 \" \" \$8}'"
+    fi
+    KUBECTL_FZF_PREVIEW_OPTIONS=(--preview-window=down:$num_fields --preview "$preview")
     (printf "${main_header}\n"; printf "${header}\n${data}\n" | column -t) \
         | fzf "${KUBECTL_FZF_PREVIEW_OPTIONS[@]}" ${KUBECTL_FZF_OPTIONS[@]} -q "$query" \
         | awk "$end_print"
@@ -442,7 +520,7 @@ _fzf_field_selector_complete()
     local main_header=$(_fzf_get_main_header $current_context $contexts $namespace)
 
     local header="Cluster Namespace FieldSelector Occurrences"
-    local data=$(cut -d' ' -f 1,2,$field_selector_field ${resource_files[@]} \
+    local data=$(_fzf_read_resource_files ${resource_files[@]} | cut -d' ' -f 1,2,$field_selector_field \
         | awk '{split($3,c,","); for (i in c){print $1,$2,c[i]; print $1,"all-namespaces",c[i]}}' | sort | uniq -c | awk '{print $2,$3,$4,$1}' | sort -k 4 -n -r)
 
     if [[ -n $namespace ]]; then
@@ -499,17 +577,76 @@ _fzf_field_selector_with_namespace()
 
 __kubectl_get_containers()
 {
-    local pod=$(echo $COMP_LINE | awk '{print $(NF)}')
+    local pod=${nouns[${#nouns[@]} -1]}
     local current_context=$(kubectl config current-context)
-    local main_header=$(_fzf_get_main_header $current_context)
-    local data=$(awk "(\$2 == \"$pod\") {print \$7}" ${KUBECTL_FZF_CACHE}/${current_context}/pods \
-        | tr ',' '\n')
-    if [[ $data == "" ]]; then
+    local header_file=$(_fzf_get_filepath $current_context "containers" "_header")
+    local resource_file=$(_fzf_get_filepath $current_context "containers" "_resource")
+
+    _fzf_fetch_rsynced_resource $current_context $KUBECTL_FZF_RSYNC_RESOURCE_CACHE_TIME "containers"
+    if [[ -z $pod || ! -f $resource_file ]]; then
         ___kubectl_get_containers $*
         return
     fi
-    printf "ContainerName\n${main_header}\n${data}" \
-        | fzf ${KUBECTL_FZF_OPTIONS[@]}
+
+    local namespace=$(__get_parameter_in_query --namespace -n)
+    namespace=${namespace:-$(__get_current_namespace $current_context)}
+    local main_header=$(_fzf_get_main_header $current_context $current_context $namespace)
+    local header=$(cat $header_file)
+    local data=$(_fzf_read_resource_files $resource_file | awk "(\$2 == \"$namespace\" && \$3 == \"$pod\")")
+    if [[ -z $data ]]; then
+        ___kubectl_get_containers $*
+        return
+    fi
+
+    local result=$( (printf "${main_header}\n"; printf "${header}\n${data}\n" | column -t) \
+        | fzf ${KUBECTL_FZF_OPTIONS[@]} \
+        | awk '{print $4}')
+    COMPREPLY=( $result )
+}
+
+# Complete release names of helm commands with the helmreleases cache, helm completion needs to be sourced first
+__helm_handle_go_custom_completion()
+{
+    local release_commands=" helm_status helm_upgrade helm_uninstall helm_history helm_rollback helm_test helm_get_all helm_get_hooks helm_get_manifest helm_get_metadata helm_get_notes helm_get_values "
+    if [[ ${#nouns[@]} -gt 0 || $release_commands != *" $last_command "* ]]; then
+        ___helm_handle_go_custom_completion $*
+        return
+    fi
+
+    local current_context=$(kubectl config current-context)
+    local header_file=$(_fzf_get_filepath $current_context "helmreleases" "_header")
+    local resource_file=$(_fzf_get_filepath $current_context "helmreleases" "_resource")
+    _fzf_fetch_rsynced_resource $current_context $KUBECTL_FZF_RSYNC_RESOURCE_CACHE_TIME "helmreleases"
+    if [[ ! -f $resource_file ]]; then
+        ___helm_handle_go_custom_completion $*
+        return
+    fi
+
+    local namespace=$(__get_parameter_in_query --namespace -n)
+    local main_header=$(_fzf_get_main_header $current_context $current_context $namespace)
+    local label_field=$(_fzf_get_header_position $header_file "Labels")
+    local status_field=$(_fzf_get_header_position $header_file "Status")
+    local end_field=$((label_field - 1))
+    local header=$(cut -d ' ' -f 1-$end_field "$header_file")
+    # Only the last revision of a release is relevant
+    local data=$(_fzf_read_resource_files $resource_file | cut -d ' ' -f 1-$end_field | awk "(\$$status_field != \"superseded\")")
+    if [[ -n $namespace ]]; then
+        data=$(echo "$data" | awk "(\$2 == \"$namespace\")")
+    fi
+
+    local result=($( (printf "${main_header}\n"; printf "${header}\n${data}\n" | column -t) \
+        | fzf ${KUBECTL_FZF_OPTIONS[@]} -q "$cur" \
+        | awk '{print $2,$3}'))
+    if [[ ${#result[@]} -ne 2 ]]; then
+        return
+    fi
+
+    local current_namespace=$(__get_current_namespace $current_context)
+    if [[ ${result[0]} != $current_namespace && $COMP_LINE != *" -n"* && $COMP_LINE != *" --namespace"* ]]; then
+        COMPREPLY=( "${result[1]} -n ${result[0]}" )
+    else
+        COMPREPLY=( ${result[1]} )
+    fi
 }
 
 __get_current_namespace()
@@ -0,0 +1,283 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kubectlfzf/pkg/util"
+
+	"github.com/pkg/errors"
+)
+
+// relationSuffix is the suffix of the relation files written by the stores
+const relationSuffix = "_relations"
+
+// leafResources are shared by many objects, they are part of the graph but not expanded when looking for related objects
+var leafResources = map[string]bool{
+	"nodes":           true,
+	"namespaces":      true,
+	"configmaps":      true,
+	"secrets":         true,
+	"serviceaccounts": true,
+}
+
+// Node is a kubernetes object of the graph
+type Node struct {
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// ID returns the unique identifier of the node
+func (n Node) ID() string {
+	if n.Namespace == "" {
+		return fmt.Sprintf("%s/%s", n.Resource, n.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", n.Resource, n.Namespace, n.Name)
+}
+
+// Edge is a relation between two objects
+type Edge struct {
+	From     Node   `json:"-"`
+	To       Node   `json:"-"`
+	Relation string `json:"relation"`
+	Details  string `json:"details,omitempty"`
+}
+
+// Graph is the object graph of a cluster
+type Graph struct {
+	Nodes map[string]Node
+	Edges []Edge
+}
+
+// NewGraph creates an empty graph
+func NewGraph() *Graph {
+	return &Graph{Nodes: make(map[string]Node)}
+}
+
+// AddEdge adds a relation and its objects to the graph
+func (g *Graph) AddEdge(edge Edge) {
+	g.Nodes[edge.From.ID()] = edge.From
+	g.Nodes[edge.To.ID()] = edge.To
+	g.Edges = append(g.Edges, edge)
+}
+
+func fieldOrEmpty(field string) string {
+	if field == "None" {
+		return ""
+	}
+	return field
+}
+
+// parseRelations reads the current relation lines of a record file:
+// Cluster Namespace Name Relation Resource TargetNamespace TargetName Details
+func (g *Graph) parseRelations(resourceName string, r io.Reader) error {
+	lines, err := util.ReadRecordLines(r)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 8 {
+			return errors.Errorf("Invalid relation line for %s: %q", resourceName, line)
+		}
+		g.AddEdge(Edge{
+			From:     Node{resourceName, fieldOrEmpty(fields[1]), fields[2]},
+			To:       Node{fields[4], fieldOrEmpty(fields[5]), fields[6]},
+			Relation: fields[3],
+			Details:  fieldOrEmpty(fields[7]),
+		})
+	}
+	return nil
+}
+
+// LoadGraph builds the graph from the relation files of a cluster cache dir
+func LoadGraph(clusterDir string) (*Graph, error) {
+	files, err := filepath.Glob(filepath.Join(clusterDir, "*"+relationSuffix))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error listing relation files in %s", clusterDir)
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("No relation files found in %s, is cache_builder running?", clusterDir)
+	}
+	g := NewGraph()
+	for _, file := range files {
+		resourceName := strings.TrimSuffix(filepath.Base(file), relationSuffix)
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error opening %s", file)
+		}
+		err = g.parseRelations(resourceName, f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading %s", file)
+		}
+	}
+	return g, nil
+}
+
+// FilterNamespace keeps the relations involving objects of the given namespace
+func (g *Graph) FilterNamespace(namespace string) *Graph {
+	res := NewGraph()
+	for _, edge := range g.Edges {
+		if edge.From.Namespace == namespace || edge.To.Namespace == namespace {
+			res.AddEdge(edge)
+		}
+	}
+	return res
+}
+
+// Related keeps the objects reachable from the root object, following relations in both directions.
+// Shared objects like nodes or configmaps are reached but not expanded
+func (g *Graph) Related(root Node) (*Graph, error) {
+	if _, ok := g.Nodes[root.ID()]; !ok {
+		return nil, errors.Errorf("%s not found in the relations", root.ID())
+	}
+	neighbours := make(map[string][]int)
+	for i, edge := range g.Edges {
+		neighbours[edge.From.ID()] = append(neighbours[edge.From.ID()], i)
+		neighbours[edge.To.ID()] = append(neighbours[edge.To.ID()], i)
+	}
+	visited := map[string]bool{root.ID(): true}
+	selectedEdges := make(map[int]bool)
+	queue := []Node{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if leafResources[node.Resource] && node.ID() != root.ID() {
+			continue
+		}
+		for _, i := range neighbours[node.ID()] {
+			selectedEdges[i] = true
+			edge := g.Edges[i]
+			for _, next := range []Node{edge.From, edge.To} {
+				if !visited[next.ID()] {
+					visited[next.ID()] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	res := NewGraph()
+	for i, edge := range g.Edges {
+		if selectedEdges[i] {
+			res.AddEdge(edge)
+		}
+	}
+	return res, nil
+}
+
+func (g *Graph) sortedNodeIDs() []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WriteDOT writes the graph in Graphviz format
+func (g *Graph) WriteDOT(w io.Writer, name string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", name)
+	b.WriteString("  node [shape=box];\n")
+	for _, id := range g.sortedNodeIDs() {
+		node := g.Nodes[id]
+		label := node.Name
+		if node.Namespace != "" {
+			label = fmt.Sprintf("%s/%s", node.Namespace, node.Name)
+		}
+		fmt.Fprintf(&b, "  %q [label=%q];\n", id, fmt.Sprintf("%s\n%s", node.Resource, label))
+	}
+	for _, edge := range g.Edges {
+		label := edge.Relation
+		if edge.Details != "" {
+			label = fmt.Sprintf("%s (%s)", edge.Relation, edge.Details)
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", edge.From.ID(), edge.To.ID(), label)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonNode struct {
+	ID string `json:"id"`
+	Node
+}
+
+type jsonEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Edge
+}
+
+type jsonGraph struct {
+	Nodes []jsonNode `json:"nodes"`
+	Edges []jsonEdge `json:"edges"`
+}
+
+// WriteJSON writes the graph as a list of nodes and edges
+func (g *Graph) WriteJSON(w io.Writer) error {
+	res := jsonGraph{Nodes: make([]jsonNode, 0, len(g.Nodes)), Edges: make([]jsonEdge, len(g.Edges))}
+	for _, id := range g.sortedNodeIDs() {
+		res.Nodes = append(res.Nodes, jsonNode{id, g.Nodes[id]})
+	}
+	for i, edge := range g.Edges {
+		res.Edges[i] = jsonEdge{edge.From.ID(), edge.To.ID(), edge}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(res)
+}
+
+// ParseRoot parses a root object given as resource/name or resource/namespace/name.
+// The namespace defaults to the given one when not in the root
+func ParseRoot(root string, namespace string) (Node, error) {
+	parts := strings.Split(root, "/")
+	switch len(parts) {
+	case 2:
+		return Node{normalizeResource(parts[0]), namespace, parts[1]}, nil
+	case 3:
+		return Node{normalizeResource(parts[0]), parts[1], parts[2]}, nil
+	}
+	return Node{}, errors.Errorf("Invalid root %q, expected resource/name or resource/namespace/name", root)
+}
+
+// resourceAliases maps short names to the resource names used in the cache
+var resourceAliases = map[string]string{
+	"po":      "pods",
+	"svc":     "services",
+	"deploy":  "deployments",
+	"rs":      "replicasets",
+	"sts":     "statefulsets",
+	"ds":      "daemonsets",
+	"cj":      "cronjobs",
+	"ing":     "ingresses",
+	"hpa":     "horizontalpodautoscalers",
+	"pv":      "persistentvolumes",
+	"pvc":     "persistentvolumeclaims",
+	"cm":      "configmaps",
+	"sa":      "serviceaccounts",
+	"no":      "nodes",
+	"ingress": "ingresses",
+}
+
+func normalizeResource(resource string) string {
+	resource = strings.ToLower(resource)
+	if alias, ok := resourceAliases[resource]; ok {
+		return alias
+	}
+	if !strings.HasSuffix(resource, "s") {
+		return resource + "s"
+	}
+	return resource
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPodRelations = `test ns1 app-1234-abcd owner replicasets ns1 app-1234 ReplicaSet
+test ns1 app-1234-abcd scheduled nodes None node1 Running
+test ns1 app-1234-abcd references configmaps ns1 shared None
+test ns1 other-pod scheduled nodes None node1 Running
+test ns1 other-pod references configmaps ns1 shared None
+test ns2 unrelated scheduled nodes None node2 Running
+`
+
+func testGraph(t *testing.T) *Graph {
+	g := NewGraph()
+	assert.Nil(t, g.parseRelations("pods", strings.NewReader(testPodRelations)))
+	assert.Nil(t, g.parseRelations("replicasets",
+		strings.NewReader("test ns1 app-1234 owner deployments ns1 app Deployment\n")))
+	return g
+}
+
+func TestRelated(t *testing.T) {
+	g := testGraph(t)
+	root, err := ParseRoot("deploy/app", "ns1")
+	assert.Nil(t, err)
+	related, err := g.Related(root)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"deployments/ns1/app", "replicasets/ns1/app-1234", "pods/ns1/app-1234-abcd",
+		"nodes/node1", "configmaps/ns1/shared",
+	}, related.sortedNodeIDs())
+
+	_, err = g.Related(Node{"deployments", "ns1", "missing"})
+	assert.NotNil(t, err)
+}
+
+func TestFilterNamespace(t *testing.T) {
+	g := testGraph(t).FilterNamespace("ns2")
+	assert.Equal(t, []string{"nodes/node2", "pods/ns2/unrelated"}, g.sortedNodeIDs())
+
+	var b bytes.Buffer
+	assert.Nil(t, g.WriteDOT(&b, "test"))
+	assert.Contains(t, b.String(), `"pods/ns2/unrelated" -> "nodes/node2" [label="scheduled (Running)"];`)
+}
+
+func TestParseRelationRecords(t *testing.T) {
+	records := `= ns1_app-1 test ns1 app-1 scheduled nodes None node1 Running
++ ns1_app-1 test ns1 app-1 references configmaps ns1 shared None
+= ns1_app-2 test ns1 app-2 scheduled nodes None node1 Pending
+= ns1_app-2 test ns1 app-2 scheduled nodes None node2 Running
+= ns1_app-3 test ns1 app-3 scheduled nodes None node1 Running
+- ns1_app-3
+`
+	g := NewGraph()
+	assert.Nil(t, g.parseRelations("pods", strings.NewReader(records)))
+	assert.Equal(t, []string{"configmaps/ns1/shared", "nodes/node1", "nodes/node2", "pods/ns1/app-1", "pods/ns1/app-2"},
+		g.sortedNodeIDs())
+	assert.Len(t, g.Edges, 3)
+}
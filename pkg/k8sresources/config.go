@@ -2,6 +2,7 @@ package k8sresources
 
 // CtorConfig is the configuration passed to all resource constructors
 type CtorConfig struct {
-	RoleBlacklist map[string]bool
-	Cluster       string
+	RoleBlacklist  map[string]bool
+	Cluster        string
+	HideSecretKeys bool
 }
@@ -5,13 +5,17 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-const ConfigMapHeader = "Cluster Namespace Name Age Labels\n"
+const ConfigMapHeader = "Cluster Namespace Name Keys UsedBy Owner Age Labels\n"
 
 // ConfigMap is the summary of a kubernetes configMap
 type ConfigMap struct {
 	ResourceMeta
+	keys         []string
+	metadataOnly bool // Set when watched with metadata only, without data
+	usedBy       string
 }
 
 // NewConfigMapFromRuntime builds a pod from informer result
@@ -23,8 +27,28 @@ func NewConfigMapFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 
 // FromRuntime builds object from the informer's result
 func (c *ConfigMap) FromRuntime(obj interface{}, config CtorConfig) {
+	if partial, ok := obj.(*metav1.PartialObjectMetadata); ok {
+		// Watched with metadata only, keys are not available
+		c.FromObjectMeta(partial.ObjectMeta, config)
+		c.metadataOnly = true
+		return
+	}
 	configMap := obj.(*corev1.ConfigMap)
 	c.FromObjectMeta(configMap.ObjectMeta, config)
+	dataKeys := make([]string, 0, len(configMap.Data))
+	for k := range configMap.Data {
+		dataKeys = append(dataKeys, k)
+	}
+	binaryDataKeys := make([]string, 0, len(configMap.BinaryData))
+	for k := range configMap.BinaryData {
+		binaryDataKeys = append(binaryDataKeys, k)
+	}
+	c.keys = getSortedKeys(dataKeys, binaryDataKeys)
+}
+
+// Resolve finds the pods using the configmap
+func (c *ConfigMap) Resolve(lookup StoreLookup) {
+	c.usedBy = c.resolveUsedBy(lookup, ConfigMapIndex)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -38,6 +62,9 @@ func (c *ConfigMap) ToString() string {
 		c.cluster,
 		c.namespace,
 		c.name,
+		keysString(c.keys, c.metadataOnly),
+		c.usedBy,
+		c.ownerString(),
 		c.resourceAge(),
 		c.labelsString(),
 	}, " ")
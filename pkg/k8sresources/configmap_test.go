@@ -0,0 +1,66 @@
+package k8sresources
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// numberedKeys returns count key names made of prefix and a two digits number
+func numberedKeys(prefix string, count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s%02d", prefix, i)
+	}
+	return keys
+}
+
+func TestKeysString(t *testing.T) {
+	longKeys := numberedKeys(strings.Repeat("k", 30), 15)
+	var testDatas = []struct {
+		name         string
+		keys         []string
+		metadataOnly bool
+		expected     string
+	}{
+		{"no key", nil, false, "None"},
+		{"metadata only", nil, true, "Unknown"},
+		{"few keys", []string{"a", "b"}, false, "a,b"},
+		{"capped count", numberedKeys("key", 25), false, strings.Join(numberedKeys("key", maxDisplayedKeys), ",") + ",..."},
+		{"capped length", longKeys, false, strings.Join(longKeys, ",")[:300]},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, keysString(testData.keys, testData.metadataOnly), testData.name)
+	}
+}
+
+func TestConfigMapKeys(t *testing.T) {
+	var testDatas = []struct {
+		name       string
+		data       map[string]string
+		binaryData map[string][]byte
+		expected   []string
+	}{
+		{"empty", nil, nil, []string{}},
+		{"data", map[string]string{"b.yaml": "b", "a.yaml": "a"}, nil, []string{"a.yaml", "b.yaml"}},
+		{"data and binary data", map[string]string{"config.yaml": ""}, map[string][]byte{"cert.der": nil, "config.yaml": nil},
+			[]string{"cert.der", "config.yaml"}},
+	}
+	for _, testData := range testDatas {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "ns1"},
+			Data:       testData.data,
+			BinaryData: testData.binaryData,
+		}
+		c := NewConfigMapFromRuntime(configMap, CtorConfig{}).(*ConfigMap)
+		assert.Equal(t, testData.expected, c.keys, testData.name)
+	}
+
+	partial := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "ns1"}}
+	c := NewConfigMapFromRuntime(partial, CtorConfig{}).(*ConfigMap)
+	assert.Equal(t, "Unknown", keysString(c.keys, c.metadataOnly))
+}
@@ -0,0 +1,127 @@
+package k8sresources
+
+import (
+	"strconv"
+	"strings"
+
+	"kubectlfzf/pkg/util"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ContainerHeader is the header for container files
+const ContainerHeader = "Cluster Namespace Pod Container Image Ready Restarts State\n"
+
+// Container is the summary of a single container of a pod
+type Container struct {
+	name     string
+	image    string
+	ready    bool
+	restarts int
+	state    string
+}
+
+// PodContainers is the summary of the containers of a kubernetes pod
+type PodContainers struct {
+	ResourceMeta
+	containers []Container
+}
+
+// getContainerState returns the current state of a container, using the reason when available
+func getContainerState(state corev1.ContainerState) string {
+	if state.Waiting != nil {
+		if state.Waiting.Reason != "" {
+			return state.Waiting.Reason
+		}
+		return "Waiting"
+	}
+	if state.Terminated != nil {
+		if state.Terminated.Reason != "" {
+			return state.Terminated.Reason
+		}
+		return "Terminated"
+	}
+	if state.Running != nil {
+		return "Running"
+	}
+	return "Unknown"
+}
+
+// containerStatusesByName indexes init and regular container statuses by container name
+func containerStatusesByName(pod *corev1.Pod) map[string]corev1.ContainerStatus {
+	res := make(map[string]corev1.ContainerStatus)
+	for _, v := range pod.Status.InitContainerStatuses {
+		res[v.Name] = v
+	}
+	for _, v := range pod.Status.ContainerStatuses {
+		res[v.Name] = v
+	}
+	return res
+}
+
+// NewPodContainersFromRuntime builds the container list of a pod from informer result
+func NewPodContainersFromRuntime(obj interface{}, config CtorConfig) K8sResource {
+	c := &PodContainers{}
+	c.FromRuntime(obj, config)
+	return c
+}
+
+// FromRuntime builds object from the informer's result
+func (c *PodContainers) FromRuntime(obj interface{}, config CtorConfig) {
+	pod := obj.(*corev1.Pod)
+	glog.V(19).Infof("Reading meta %#v", pod)
+	c.FromObjectMeta(pod.ObjectMeta, config)
+
+	statuses := containerStatusesByName(pod)
+	containers := pod.Spec.Containers
+	containers = append(containers, pod.Spec.InitContainers...)
+	c.containers = make([]Container, len(containers))
+	for k, v := range containers {
+		container := Container{name: v.Name, image: v.Image, state: "Pending"}
+		if status, ok := statuses[v.Name]; ok {
+			container.ready = status.Ready
+			container.restarts = int(status.RestartCount)
+			container.state = getContainerState(status.State)
+		}
+		c.containers[k] = container
+	}
+}
+
+// Relations returns nothing, relations of the pod are already written by the pod store
+func (c *PodContainers) Relations() []Relation {
+	return nil
+}
+
+// HasChanged returns true if the resource's dump needs to be updated
+func (c *PodContainers) HasChanged(k K8sResource) bool {
+	oldC := k.(*PodContainers)
+	if len(c.containers) != len(oldC.containers) {
+		return true
+	}
+	for i := range c.containers {
+		if c.containers[i] != oldC.containers[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// ToString serializes the object to strings, one line per container
+func (c *PodContainers) ToString() string {
+	var res strings.Builder
+	for _, container := range c.containers {
+		lst := []string{
+			c.cluster,
+			c.namespace,
+			c.name,
+			container.name,
+			container.image,
+			strconv.FormatBool(container.ready),
+			strconv.Itoa(container.restarts),
+			container.state,
+		}
+		res.WriteString(util.DumpLine(lst))
+	}
+	return res.String()
+}
@@ -0,0 +1,43 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodContainers(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate", Image: "migrate:1"}},
+			Containers: []corev1.Container{
+				{Name: "app", Image: "web:1"},
+				{Name: "proxy", Image: "proxy:1"},
+				{Name: "sidecar", Image: "sidecar:1"},
+			},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "migrate",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true, RestartCount: 2, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "proxy", RestartCount: 5, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		},
+	}
+	c := NewPodContainersFromRuntime(pod, CtorConfig{Cluster: "test"})
+	expected := "test default web app web:1 true 2 Running\n" +
+		"test default web proxy proxy:1 false 5 CrashLoopBackOff\n" +
+		"test default web sidecar sidecar:1 false 0 Pending\n" +
+		"test default web migrate migrate:1 false 0 Completed\n"
+	assert.Equal(t, expected, c.ToString())
+	assert.False(t, c.HasChanged(NewPodContainersFromRuntime(pod, CtorConfig{Cluster: "test"})))
+
+	pod.Status.ContainerStatuses[1].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	assert.True(t, NewPodContainersFromRuntime(pod, CtorConfig{Cluster: "test"}).HasChanged(c))
+}
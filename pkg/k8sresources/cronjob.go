@@ -1,21 +1,30 @@
 package k8sresources
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/golang/glog"
+	batchv1 "k8s.io/api/batch/v1"
 	batchbetav1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubectlfzf/pkg/util"
-	"strings"
 )
 
 // CronJobHeader is the headers for cronjob files
-const CronJobHeader = "Cluster Namespace Name Schedule LastSchedule Containers Age Labels\n"
+const CronJobHeader = "Cluster Namespace Name Schedule TimeZone Suspend Active LastSchedule LastSuccessful Containers Owner Age Labels\n"
 
 // CronJob is the summary of a kubernetes cronJob
 type CronJob struct {
 	ResourceMeta
-	schedule     string
-	lastSchedule string
-	containers   []string
+	schedule       string
+	timeZone       string
+	suspend        string
+	active         string
+	lastSchedule   string
+	lastSuccessful string
+	containers     []string
 }
 
 // NewCronJobFromRuntime builds a cronJob from informer result
@@ -27,16 +36,32 @@ func NewCronJobFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 
 // FromRuntime builds object from the informer's result
 func (c *CronJob) FromRuntime(obj interface{}, config CtorConfig) {
-	cronJob := obj.(*batchbetav1.CronJob)
-	glog.V(19).Infof("Reading meta %#v", cronJob)
-	c.FromObjectMeta(cronJob.ObjectMeta, config)
-	c.schedule = strings.ReplaceAll(cronJob.Spec.Schedule, " ", "_")
-	c.lastSchedule = ""
-	if cronJob.Status.LastScheduleTime != nil {
-		c.lastSchedule = util.TimeToAge(cronJob.Status.LastScheduleTime.Time)
+	glog.V(19).Infof("Reading meta %#v", obj)
+	switch cronJob := obj.(type) {
+	case *batchv1.CronJob:
+		c.FromObjectMeta(cronJob.ObjectMeta, config)
+		c.fromSpec(cronJob.Spec.Schedule, cronJob.Spec.TimeZone, cronJob.Spec.Suspend,
+			cronJob.Spec.JobTemplate.Spec.Template.Spec)
+		c.fromStatus(cronJob.Status.Active, cronJob.Status.LastScheduleTime, cronJob.Status.LastSuccessfulTime)
+	case *batchbetav1.CronJob:
+		c.FromObjectMeta(cronJob.ObjectMeta, config)
+		c.fromSpec(cronJob.Spec.Schedule, cronJob.Spec.TimeZone, cronJob.Spec.Suspend,
+			cronJob.Spec.JobTemplate.Spec.Template.Spec)
+		c.fromStatus(cronJob.Status.Active, cronJob.Status.LastScheduleTime, cronJob.Status.LastSuccessfulTime)
+	}
+}
+
+func (c *CronJob) fromSpec(schedule string, timeZone *string, suspend *bool, spec corev1.PodSpec) {
+	c.schedule = strings.ReplaceAll(schedule, " ", "_")
+	c.timeZone = ""
+	if timeZone != nil {
+		c.timeZone = *timeZone
+	}
+	c.suspend = "false"
+	if suspend != nil {
+		c.suspend = strconv.FormatBool(*suspend)
 	}
 
-	spec := cronJob.Spec.JobTemplate.Spec.Template.Spec
 	containers := spec.Containers
 	containers = append(containers, spec.InitContainers...)
 	c.containers = make([]string, len(containers))
@@ -45,6 +70,18 @@ func (c *CronJob) FromRuntime(obj interface{}, config CtorConfig) {
 	}
 }
 
+func (c *CronJob) fromStatus(active []corev1.ObjectReference, lastScheduleTime *metav1.Time, lastSuccessfulTime *metav1.Time) {
+	c.active = strconv.Itoa(len(active))
+	c.lastSchedule = ""
+	if lastScheduleTime != nil {
+		c.lastSchedule = util.TimeToAge(lastScheduleTime.Time)
+	}
+	c.lastSuccessful = ""
+	if lastSuccessfulTime != nil {
+		c.lastSuccessful = util.TimeToAge(lastSuccessfulTime.Time)
+	}
+}
+
 // HasChanged returns true if the resource's dump needs to be updated
 func (c *CronJob) HasChanged(k K8sResource) bool {
 	return true
@@ -57,8 +94,13 @@ func (c *CronJob) ToString() string {
 		c.namespace,
 		c.name,
 		c.schedule,
+		c.timeZone,
+		c.suspend,
+		c.active,
 		c.lastSchedule,
+		c.lastSuccessful,
 		util.JoinSlicesOrNone(c.containers, ","),
+		c.ownerString(),
 		c.resourceAge(),
 		c.labelsString(),
 	}
@@ -0,0 +1,68 @@
+package k8sresources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	batchbetav1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCronJobFromRuntime(t *testing.T) {
+	suspended := true
+	timeZone := "Europe/Paris"
+	lastSchedule := metav1.NewTime(time.Now().Add(-90 * time.Minute))
+	lastSuccess := metav1.NewTime(time.Now().Add(-50 * time.Hour))
+	var testDatas = []struct {
+		name                 string
+		spec                 batchv1.CronJobSpec
+		status               batchv1.CronJobStatus
+		expectedSchedule     string
+		expectedTimeZone     string
+		expectedSuspend      string
+		expectedActive       string
+		expectedLastSchedule string
+		expectedLastSuccess  string
+	}{
+		{"never scheduled", batchv1.CronJobSpec{Schedule: "*/5 * * * *"}, batchv1.CronJobStatus{},
+			"*/5_*_*_*_*", "", "false", "0", "", ""},
+		{"suspended with time zone", batchv1.CronJobSpec{Schedule: "@daily", Suspend: &suspended, TimeZone: &timeZone},
+			batchv1.CronJobStatus{}, "@daily", "Europe/Paris", "true", "0", "", ""},
+		{"scheduled", batchv1.CronJobSpec{Schedule: "0 * * * *"}, batchv1.CronJobStatus{
+			Active:             []corev1.ObjectReference{{Kind: "Job", Name: "a"}, {Kind: "Job", Name: "b"}},
+			LastScheduleTime:   &lastSchedule,
+			LastSuccessfulTime: &lastSuccess,
+		}, "0_*_*_*_*", "", "false", "2", "01:30", "2d"},
+	}
+	for _, testData := range testDatas {
+		cronJob := &batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "ns1"},
+			Spec:       testData.spec,
+			Status:     testData.status,
+		}
+		c := NewCronJobFromRuntime(cronJob, CtorConfig{}).(*CronJob)
+		assert.Equal(t, testData.expectedSchedule, c.schedule, testData.name)
+		assert.Equal(t, testData.expectedTimeZone, c.timeZone, testData.name)
+		assert.Equal(t, testData.expectedSuspend, c.suspend, testData.name)
+		assert.Equal(t, testData.expectedActive, c.active, testData.name)
+		assert.Equal(t, testData.expectedLastSchedule, c.lastSchedule, testData.name)
+		assert.Equal(t, testData.expectedLastSuccess, c.lastSuccessful, testData.name)
+	}
+}
+
+func TestCronJobFromV1beta1(t *testing.T) {
+	suspended := true
+	lastSchedule := metav1.NewTime(time.Now().Add(-90 * time.Minute))
+	cronJob := &batchbetav1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "ns1"},
+		Spec:       batchbetav1.CronJobSpec{Schedule: "0 * * * *", Suspend: &suspended},
+		Status:     batchbetav1.CronJobStatus{LastScheduleTime: &lastSchedule},
+	}
+	c := NewCronJobFromRuntime(cronJob, CtorConfig{}).(*CronJob)
+	assert.Equal(t, "0_*_*_*_*", c.schedule)
+	assert.Equal(t, "true", c.suspend)
+	assert.Equal(t, "01:30", c.lastSchedule)
+}
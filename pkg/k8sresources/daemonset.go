@@ -10,7 +10,11 @@ import (
 )
 
 // DaemonSetHeader is the header file for daemonset
-const DaemonSetHeader = "Cluster Namespace Name Desired Current Ready LabelSelector Containers Age Labels\n"
+const DaemonSetHeader = "Cluster Namespace Name Desired Current Ready Up-to-date Available LabelSelector Generation Strategy CurrentRevision UpdateRevision Containers Images Owner Age Labels\n"
+
+// podTemplateGenerationLabel holds the generation of the daemonset's pod template a pod was created from,
+// the daemonset keeps its current template generation in the appsv1.DeprecatedTemplateGeneration annotation
+const podTemplateGenerationLabel = "pod-template-generation"
 
 // DaemonSet is the summary of a kubernetes daemonset
 type DaemonSet struct {
@@ -18,8 +22,18 @@ type DaemonSet struct {
 	desired       string
 	current       string
 	ready         string
+	updated       string
+	available     string
 	containers    []string
+	images        []string
 	labelSelector []string
+	generation    string
+	strategy      string
+	selector      map[string]string
+	// The daemonset's status has no revision, they are read from the labels of its pods
+	templateGeneration string
+	currentRevision    string
+	updateRevision     string
 }
 
 // NewDaemonSetFromRuntime builds a daemonset from informer result
@@ -39,11 +53,18 @@ func (d *DaemonSet) FromRuntime(obj interface{}, config CtorConfig) {
 	d.desired = strconv.Itoa(int(status.DesiredNumberScheduled))
 	d.current = strconv.Itoa(int(status.CurrentNumberScheduled))
 	d.ready = strconv.Itoa(int(status.NumberReady))
+	d.updated = strconv.Itoa(int(status.UpdatedNumberScheduled))
+	d.available = strconv.Itoa(int(status.NumberAvailable))
+	d.generation = generationStatus(daemonset.Generation, status.ObservedGeneration)
+	d.strategy = string(daemonset.Spec.UpdateStrategy.Type)
+
+	d.templateGeneration = daemonset.Annotations[appsv1.DeprecatedTemplateGeneration]
 
 	d.labelSelector = make([]string, 0)
 	for k, v := range daemonset.Spec.Selector.MatchLabels {
 		d.labelSelector = append(d.labelSelector, fmt.Sprintf("%s=%s", k, v))
 	}
+	d.selector = daemonset.Spec.Selector.MatchLabels
 
 	podSpec := daemonset.Spec.Template.Spec
 	containers := podSpec.Containers
@@ -52,11 +73,59 @@ func (d *DaemonSet) FromRuntime(obj interface{}, config CtorConfig) {
 	for k, v := range containers {
 		d.containers[k] = v.Name
 	}
+	d.images = getImages(containers)
+}
+
+// Resolve reads the revisions from the daemonset's pods: the current revision is the one of the
+// oldest template generation still running, the update revision the one of the template generation
+func (d *DaemonSet) Resolve(lookup StoreLookup) {
+	d.currentRevision = "Unknown"
+	d.updateRevision = "Unknown"
+	candidates, ok := selectPods(lookup, d.namespace, d.selector)
+	if !ok {
+		return
+	}
+	d.currentRevision = "None"
+	d.updateRevision = "None"
+	oldestGeneration := -1
+	for _, pod := range candidates {
+		if pod.ownerKind != "DaemonSet" || pod.ownerName != d.name {
+			continue
+		}
+		revision := pod.labels[appsv1.ControllerRevisionHashLabelKey]
+		generation, err := strconv.Atoi(pod.labels[podTemplateGenerationLabel])
+		if revision == "" || err != nil {
+			continue
+		}
+		if oldestGeneration < 0 || generation < oldestGeneration {
+			oldestGeneration = generation
+			d.currentRevision = revision
+		}
+		if strconv.Itoa(generation) == d.templateGeneration {
+			d.updateRevision = revision
+		}
+	}
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
 func (d *DaemonSet) HasChanged(k K8sResource) bool {
-	return true
+	oldDs := k.(*DaemonSet)
+	return (d.desired != oldDs.desired ||
+		d.current != oldDs.current ||
+		d.ready != oldDs.ready ||
+		d.updated != oldDs.updated ||
+		d.available != oldDs.available ||
+		d.generation != oldDs.generation ||
+		d.strategy != oldDs.strategy ||
+		d.templateGeneration != oldDs.templateGeneration ||
+		d.currentRevision != oldDs.currentRevision ||
+		d.updateRevision != oldDs.updateRevision ||
+		!util.StringSlicesEqual(d.labelSelector, oldDs.labelSelector) ||
+		!util.StringSlicesEqual(d.containers, oldDs.containers) ||
+		!util.StringSlicesEqual(d.images, oldDs.images) ||
+		d.ownerKind != oldDs.ownerKind ||
+		d.ownerName != oldDs.ownerName ||
+		!util.StringMapsEqual(d.labels, oldDs.labels))
 }
 
 // ToString serializes the object to strings
@@ -68,8 +137,16 @@ func (d *DaemonSet) ToString() string {
 		d.desired,
 		d.current,
 		d.ready,
+		d.updated,
+		d.available,
 		util.JoinSlicesOrNone(d.labelSelector, ","),
+		d.generation,
+		d.strategy,
+		d.currentRevision,
+		d.updateRevision,
 		util.JoinSlicesOrNone(d.containers, ","),
+		util.JoinSlicesOrNone(d.images, ","),
+		d.ownerString(),
 		d.resourceAge(),
 		d.labelsString(),
 	}
@@ -0,0 +1,101 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testDaemonSet() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "agent", Namespace: "ns1", Generation: 2,
+			Labels:      map[string]string{"app": "agent"},
+			Annotations: map[string]string{appsv1.DeprecatedTemplateGeneration: "2", "note": "stripped"},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "agent"}},
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "agent", Image: "agent:2"}},
+			}},
+		},
+		Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, CurrentNumberScheduled: 2, ObservedGeneration: 2},
+	}
+}
+
+// daemonSetPod returns the summary of a pod created by the agent daemonset from a template generation
+func daemonSetPod(name string, owner string, generation string, revision string) K8sResource {
+	controller := true
+	return NewPodFromRuntime(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: "ns1",
+		Labels: map[string]string{
+			"app": "agent", podTemplateGenerationLabel: generation, appsv1.ControllerRevisionHashLabelKey: revision,
+		},
+		OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: owner, Controller: &controller}},
+	}}, CtorConfig{})
+}
+
+func TestDaemonSetResolve(t *testing.T) {
+	var testDatas = []struct {
+		name            string
+		pods            []K8sResource
+		expectedCurrent string
+		expectedUpdate  string
+	}{
+		{"pods not watched", nil, "Unknown", "Unknown"},
+		{"no pod", []K8sResource{}, "None", "None"},
+		{"updated", []K8sResource{daemonSetPod("a", "agent", "2", "rev2"), daemonSetPod("b", "agent", "2", "rev2")}, "rev2", "rev2"},
+		{"rolling out", []K8sResource{daemonSetPod("a", "agent", "2", "rev2"), daemonSetPod("b", "agent", "1", "rev1")}, "rev1", "rev2"},
+		{"not started", []K8sResource{daemonSetPod("a", "agent", "1", "rev1")}, "rev1", "None"},
+		{"other daemonset", []K8sResource{daemonSetPod("a", "agent", "2", "rev2"), daemonSetPod("b", "agent-canary", "1", "rev1")}, "rev2", "rev2"},
+	}
+	for _, testData := range testDatas {
+		obj, err := StripObject(testDaemonSet())
+		assert.Nil(t, err)
+		d := NewDaemonSetFromRuntime(obj, CtorConfig{}).(*DaemonSet)
+		// The template generation annotation is kept by StripObject
+		d.Resolve(fakeLookup{testData.pods})
+		assert.Equal(t, testData.expectedCurrent, d.currentRevision, testData.name)
+		assert.Equal(t, testData.expectedUpdate, d.updateRevision, testData.name)
+	}
+}
+
+func TestDaemonSetHasChanged(t *testing.T) {
+	var testDatas = []struct {
+		name    string
+		update  func(*appsv1.DaemonSet)
+		changed bool
+	}{
+		{"unchanged", func(*appsv1.DaemonSet) {}, false},
+		{"desired", func(d *appsv1.DaemonSet) { d.Status.DesiredNumberScheduled = 3 }, true},
+		{"ready", func(d *appsv1.DaemonSet) { d.Status.NumberReady = 1 }, true},
+		{"updated", func(d *appsv1.DaemonSet) { d.Status.UpdatedNumberScheduled = 1 }, true},
+		{"available", func(d *appsv1.DaemonSet) { d.Status.NumberAvailable = 1 }, true},
+		{"generation", func(d *appsv1.DaemonSet) { d.Generation = 3 }, true},
+		{"strategy", func(d *appsv1.DaemonSet) { d.Spec.UpdateStrategy.Type = appsv1.OnDeleteDaemonSetStrategyType }, true},
+		{"template generation", func(d *appsv1.DaemonSet) { d.Annotations[appsv1.DeprecatedTemplateGeneration] = "3" }, true},
+		{"image", func(d *appsv1.DaemonSet) { d.Spec.Template.Spec.Containers[0].Image = "agent:3" }, true},
+		{"labels", func(d *appsv1.DaemonSet) { d.Labels = map[string]string{"app": "agent", "team": "infra"} }, true},
+	}
+	pods := fakeLookup{[]K8sResource{daemonSetPod("a", "agent", "2", "rev2")}}
+	for _, testData := range testDatas {
+		oldDs := NewDaemonSetFromRuntime(testDaemonSet(), CtorConfig{}).(*DaemonSet)
+		oldDs.Resolve(pods)
+		ds := testDaemonSet()
+		testData.update(ds)
+		newDs := NewDaemonSetFromRuntime(ds, CtorConfig{}).(*DaemonSet)
+		newDs.Resolve(pods)
+		assert.Equal(t, testData.changed, newDs.HasChanged(oldDs), testData.name)
+	}
+
+	// A pod of a new revision changes the resolved revisions
+	oldDs := NewDaemonSetFromRuntime(testDaemonSet(), CtorConfig{}).(*DaemonSet)
+	oldDs.Resolve(pods)
+	newDs := NewDaemonSetFromRuntime(testDaemonSet(), CtorConfig{}).(*DaemonSet)
+	newDs.Resolve(fakeLookup{[]K8sResource{daemonSetPod("a", "agent", "1", "rev1")}})
+	assert.True(t, newDs.HasChanged(oldDs))
+}
@@ -1,6 +1,7 @@
 package k8sresources
 
 import (
+	"fmt"
 	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -8,7 +9,7 @@ import (
 )
 
 // DeploymentHeader is the header file for deployment
-const DeploymentHeader = "Cluster Namespace Name Desired Current Up-to-date Available Age Labels\n"
+const DeploymentHeader = "Cluster Namespace Name Desired Current Up-to-date Available Conditions Generation Strategy Images Owner Age Labels\n"
 
 // Deployment is the summary of a kubernetes deployment
 type Deployment struct {
@@ -17,6 +18,10 @@ type Deployment struct {
 	availableReplicas string
 	updatedReplicas   string
 	currentReplicas   string
+	conditions        []string
+	generation        string
+	strategy          string
+	images            []string
 }
 
 // NewDeploymentFromRuntime builds a k8sresource from informer result
@@ -39,6 +44,17 @@ func (d *Deployment) FromRuntime(obj interface{}, config CtorConfig) {
 	d.currentReplicas = strconv.Itoa(int(status.Replicas))
 	d.updatedReplicas = strconv.Itoa(int(status.UpdatedReplicas))
 	d.availableReplicas = strconv.Itoa(int(status.AvailableReplicas))
+
+	d.conditions = make([]string, 0)
+	for _, condition := range status.Conditions {
+		if condition.Reason == "" {
+			continue
+		}
+		d.conditions = append(d.conditions, fmt.Sprintf("%s:%s", condition.Type, condition.Reason))
+	}
+	d.generation = generationStatus(deployment.Generation, status.ObservedGeneration)
+	d.strategy = string(deployment.Spec.Strategy.Type)
+	d.images = getImages(append(deployment.Spec.Template.Spec.Containers, deployment.Spec.Template.Spec.InitContainers...))
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -56,6 +72,11 @@ func (d *Deployment) ToString() string {
 		d.currentReplicas,
 		d.updatedReplicas,
 		d.availableReplicas,
+		util.JoinSlicesOrNone(d.conditions, ","),
+		d.generation,
+		d.strategy,
+		util.JoinSlicesOrNone(d.images, ","),
+		d.ownerString(),
 		d.resourceAge(),
 		d.labelsString(),
 	}
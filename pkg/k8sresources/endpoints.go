@@ -8,7 +8,7 @@ import (
 	"kubectlfzf/pkg/util"
 )
 
-const EndpointsHeader = "Cluster Namespace Name Age ReadyIps ReadyPods NotReadyIps NotReadyPods Labels\n"
+const EndpointsHeader = "Cluster Namespace Name Owner Age ReadyIps ReadyPods NotReadyIps NotReadyPods Labels\n"
 
 // Endpoints is the summary of a kubernetes endpoints
 type Endpoints struct {
@@ -61,6 +61,7 @@ func (e *Endpoints) ToString() string {
 		e.cluster,
 		e.namespace,
 		e.name,
+		e.ownerString(),
 		e.resourceAge(),
 		util.JoinSlicesWithMaxOrNone(e.readyIps, 20, ","),
 		util.JoinSlicesWithMaxOrNone(e.readyPods, 20, ","),
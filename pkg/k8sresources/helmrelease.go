@@ -0,0 +1,174 @@
+package k8sresources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubectlfzf/pkg/util"
+)
+
+// HelmReleaseHeader is the header for helm release files
+const HelmReleaseHeader = "Cluster Namespace Name Chart ChartVersion AppVersion Revision Status Updated Age Labels\n"
+
+// HelmReleaseSecretType is the type of the secrets used by helm v3 to store releases
+const HelmReleaseSecretType = "helm.sh/release.v1"
+
+// gzipMagic is the header of gzip payloads, helm compresses releases since v3.0
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// helmReleasePayload holds the metadata fields of a helm release, manifests and values are ignored
+type helmReleasePayload struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		LastDeployed time.Time `json:"last_deployed"`
+		Status       string    `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// decodeHelmRelease decodes the release stored in a helm secret: base64 encoded gzipped json
+func decodeHelmRelease(data []byte) (*helmReleasePayload, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error decoding base64 payload")
+	}
+	if bytes.HasPrefix(b, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading gzip payload")
+		}
+		defer r.Close()
+		b, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error decompressing payload")
+		}
+	}
+	release := &helmReleasePayload{}
+	err = json.Unmarshal(b, release)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error unmarshaling release")
+	}
+	return release, nil
+}
+
+// stripHelmRelease replaces the payload of a helm secret by the metadata fields of the release.
+// Manifests and values are neither kept in memory nor written to the journals
+func stripHelmRelease(data []byte) []byte {
+	release, err := decodeHelmRelease(data)
+	if err != nil {
+		return nil
+	}
+	b, err := json.Marshal(release)
+	if err != nil {
+		return nil
+	}
+	return []byte(base64.StdEncoding.EncodeToString(b))
+}
+
+// HelmRelease is the summary of a helm v3 release revision
+type HelmRelease struct {
+	ResourceMeta
+	chart        string
+	chartVersion string
+	appVersion   string
+	revision     string
+	status       string
+	updated      string
+}
+
+// helmReleaseNamePrefix is the name prefix of helm v3 release secrets
+const helmReleaseNamePrefix = "sh.helm.release.v1."
+
+// NewHelmReleaseFromRuntime builds a helm release from a release secret. Other secrets are ignored
+func NewHelmReleaseFromRuntime(obj interface{}, config CtorConfig) K8sResource {
+	switch secret := obj.(type) {
+	case *corev1.Secret:
+		if secret.Type != HelmReleaseSecretType {
+			return nil
+		}
+	case *metav1.PartialObjectMetadata:
+		// The secret type is not part of the metadata
+		if secret.Labels["owner"] != "helm" || !strings.HasPrefix(secret.Name, helmReleaseNamePrefix) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	h := &HelmRelease{}
+	h.FromRuntime(obj, config)
+	return h
+}
+
+// FromRuntime builds object from the informer's result
+func (h *HelmRelease) FromRuntime(obj interface{}, config CtorConfig) {
+	if partial, ok := obj.(*metav1.PartialObjectMetadata); ok {
+		h.fromMeta(partial.ObjectMeta, config)
+		return
+	}
+	secret := obj.(*corev1.Secret)
+	h.fromMeta(secret.ObjectMeta, config)
+
+	release, err := decodeHelmRelease(secret.Data["release"])
+	if err != nil {
+		glog.Warningf("Error decoding helm release %s/%s: %v", secret.Namespace, secret.Name, err)
+		return
+	}
+	h.name = release.Name
+	h.status = release.Info.Status
+	h.revision = strconv.Itoa(release.Version)
+	h.chart = release.Chart.Metadata.Name
+	h.chartVersion = release.Chart.Metadata.Version
+	h.appVersion = release.Chart.Metadata.AppVersion
+	if !release.Info.LastDeployed.IsZero() {
+		h.updated = util.TimeToAge(release.Info.LastDeployed)
+	}
+}
+
+// fromMeta fills the release from the labels set by helm, used when the payload is not available
+func (h *HelmRelease) fromMeta(meta metav1.ObjectMeta, config CtorConfig) {
+	h.FromObjectMeta(meta, config)
+	h.name = meta.Labels["name"]
+	h.status = meta.Labels["status"]
+	h.revision = meta.Labels["version"]
+}
+
+// HasChanged returns true if the resource's dump needs to be updated
+func (h *HelmRelease) HasChanged(k K8sResource) bool {
+	return true
+}
+
+// ToString serializes the object to strings
+func (h *HelmRelease) ToString() string {
+	lst := []string{
+		h.cluster,
+		h.namespace,
+		h.name,
+		h.chart,
+		h.chartVersion,
+		h.appVersion,
+		h.revision,
+		h.status,
+		h.updated,
+		h.resourceAge(),
+		h.labelsString(),
+	}
+	return util.DumpLine(lst)
+}
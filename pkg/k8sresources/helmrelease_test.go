@@ -0,0 +1,83 @@
+package k8sresources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testRelease = `{"name":"web","namespace":"ns1","version":3,"info":{"status":"deployed","last_deployed":"2022-01-02T03:04:05Z"},` +
+	`"chart":{"metadata":{"name":"nginx","version":"1.2.3","appVersion":"1.21"}},"manifest":"kind: Service"}`
+
+func gzipString(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(s))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return b.Bytes()
+}
+
+func TestDecodeHelmRelease(t *testing.T) {
+	var testDatas = []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{"gzipped", []byte(base64.StdEncoding.EncodeToString(gzipString(t, testRelease))), true},
+		{"not compressed", []byte(base64.StdEncoding.EncodeToString([]byte(testRelease))), true},
+		{"not base64", []byte("not base64!"), false},
+		{"corrupt gzip", []byte(base64.StdEncoding.EncodeToString(gzipString(t, testRelease)[:20])), false},
+		{"not json", []byte(base64.StdEncoding.EncodeToString(gzipString(t, "kind: Service"))), false},
+		{"empty", nil, false},
+	}
+	for _, testData := range testDatas {
+		release, err := decodeHelmRelease(testData.data)
+		if !testData.expected {
+			assert.NotNil(t, err, testData.name)
+			continue
+		}
+		assert.Nil(t, err, testData.name)
+		assert.Equal(t, "web", release.Name, testData.name)
+		assert.Equal(t, 3, release.Version, testData.name)
+		assert.Equal(t, "deployed", release.Info.Status, testData.name)
+		assert.Equal(t, "nginx", release.Chart.Metadata.Name, testData.name)
+		assert.Equal(t, "1.2.3", release.Chart.Metadata.Version, testData.name)
+		assert.Equal(t, "1.21", release.Chart.Metadata.AppVersion, testData.name)
+		assert.Equal(t, 2022, release.Info.LastDeployed.Year(), testData.name)
+	}
+}
+
+func TestHelmReleaseFromRuntime(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name: "sh.helm.release.v1.web.v3", Namespace: "ns1",
+		Labels: map[string]string{"name": "web", "owner": "helm", "status": "superseded", "version": "3"},
+	}
+	var testDatas = []struct {
+		name     string
+		obj      interface{}
+		expected []string // name chart chartVersion appVersion revision status
+	}{
+		{"decoded", &corev1.Secret{ObjectMeta: meta, Type: HelmReleaseSecretType, Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(gzipString(t, testRelease))),
+		}}, []string{"web", "nginx", "1.2.3", "1.21", "3", "deployed"}},
+		// The labels set by helm are used when the payload can't be decoded
+		{"corrupt payload", &corev1.Secret{ObjectMeta: meta, Type: HelmReleaseSecretType, Data: map[string][]byte{
+			"release": []byte("corrupt"),
+		}}, []string{"web", "", "", "", "3", "superseded"}},
+		{"metadata only", &metav1.PartialObjectMetadata{ObjectMeta: meta}, []string{"web", "", "", "", "3", "superseded"}},
+	}
+	for _, testData := range testDatas {
+		h := NewHelmReleaseFromRuntime(testData.obj, CtorConfig{}).(*HelmRelease)
+		assert.Equal(t, testData.expected, []string{h.name, h.chart, h.chartVersion, h.appVersion, h.revision, h.status}, testData.name)
+	}
+
+	// Other secrets are not releases
+	assert.Nil(t, NewHelmReleaseFromRuntime(&corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque}, CtorConfig{}))
+	assert.Nil(t, NewHelmReleaseFromRuntime(&metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "web"}}, CtorConfig{}))
+}
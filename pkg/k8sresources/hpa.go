@@ -2,21 +2,30 @@ package k8sresources
 
 import (
 	"fmt"
-	"strings"
 
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"kubectlfzf/pkg/util"
 )
 
-const HpaHeader = "Cluster Namespace Name Reference Targets MinPods MaxPods Replicas Age Labels\n"
+const HpaHeader = "Cluster Namespace Name Reference Workload Targets MinPods MaxPods Replicas AbleToScale ScalingLimited LastScale Owner Age Labels\n"
 
 // Hpa is the summary of a kubernetes horizontal pod autoscaler
 type Hpa struct {
 	ResourceMeta
 	reference       string
-	targets         string
+	targetKind      string
+	targetName      string
+	workload        string
+	relations       []Relation
+	targets         []string
 	minPods         string
 	maxPods         string
 	currentReplicas string
+	ableToScale     string
+	scalingLimited  string
+	lastScale       string
 }
 
 // NewHpaFromRuntime builds a pod from informer result
@@ -28,17 +37,286 @@ func NewHpaFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 
 // FromRuntime builds object from the informer's result
 func (h *Hpa) FromRuntime(obj interface{}, config CtorConfig) {
-	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	switch hpa := obj.(type) {
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		h.fromV2(hpa, config)
+	case *autoscalingv2beta2.HorizontalPodAutoscaler:
+		h.fromV2(hpaFromV2beta2(hpa), config)
+	case *autoscalingv1.HorizontalPodAutoscaler:
+		h.fromV1(hpa, config)
+	}
+}
+
+func (h *Hpa) fromV1(hpa *autoscalingv1.HorizontalPodAutoscaler, config CtorConfig) {
+	h.FromObjectMeta(hpa.ObjectMeta, config)
+	h.targetKind = hpa.Spec.ScaleTargetRef.Kind
+	h.targetName = hpa.Spec.ScaleTargetRef.Name
+	h.reference = fmt.Sprintf("%s/%s", h.targetKind, h.targetName)
+	h.minPods = "None"
+	if hpa.Spec.MinReplicas != nil {
+		h.minPods = fmt.Sprintf("%d", *hpa.Spec.MinReplicas)
+	}
+	h.maxPods = fmt.Sprintf("%d", hpa.Spec.MaxReplicas)
+	h.currentReplicas = fmt.Sprintf("%d", hpa.Status.CurrentReplicas)
+
+	current := "<unknown>"
+	if hpa.Status.CurrentCPUUtilizationPercentage != nil {
+		current = fmt.Sprintf("%d%%", *hpa.Status.CurrentCPUUtilizationPercentage)
+	}
+	target := "<auto>"
+	if hpa.Spec.TargetCPUUtilizationPercentage != nil {
+		target = fmt.Sprintf("%d%%", *hpa.Spec.TargetCPUUtilizationPercentage)
+	}
+	h.targets = []string{fmt.Sprintf("cpu:%s/%s", current, target)}
+	if hpa.Status.LastScaleTime != nil {
+		h.lastScale = util.TimeToAge(hpa.Status.LastScaleTime.Time)
+	}
+}
+
+func (h *Hpa) fromV2(hpa *autoscalingv2.HorizontalPodAutoscaler, config CtorConfig) {
 	h.FromObjectMeta(hpa.ObjectMeta, config)
-	h.reference = fmt.Sprintf("%s/%s",
-		hpa.Spec.ScaleTargetRef.Kind,
-		hpa.Spec.ScaleTargetRef.Name)
+	h.targetKind = hpa.Spec.ScaleTargetRef.Kind
+	h.targetName = hpa.Spec.ScaleTargetRef.Name
+	h.reference = fmt.Sprintf("%s/%s", h.targetKind, h.targetName)
 	h.minPods = "None"
 	if hpa.Spec.MinReplicas != nil {
 		h.minPods = fmt.Sprintf("%d", *hpa.Spec.MinReplicas)
 	}
 	h.maxPods = fmt.Sprintf("%d", hpa.Spec.MaxReplicas)
 	h.currentReplicas = fmt.Sprintf("%d", hpa.Status.CurrentReplicas)
+	h.targets = getHpaTargets(hpa.Spec.Metrics, hpa.Status.CurrentMetrics)
+
+	for _, condition := range hpa.Status.Conditions {
+		value := condition.Reason
+		if value == "" {
+			value = string(condition.Status)
+		}
+		switch condition.Type {
+		case autoscalingv2.AbleToScale:
+			h.ableToScale = value
+		case autoscalingv2.ScalingLimited:
+			h.scalingLimited = value
+		}
+	}
+	if hpa.Status.LastScaleTime != nil {
+		h.lastScale = util.TimeToAge(hpa.Status.LastScaleTime.Time)
+	}
+}
+
+// hpaFromV2beta2 converts the fields of a v2beta2 hpa used by the summary, v2beta2 has the same schema as v2
+func hpaFromV2beta2(hpa *autoscalingv2beta2.HorizontalPodAutoscaler) *autoscalingv2.HorizontalPodAutoscaler {
+	hpaV2 := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: hpa.ObjectMeta,
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference(hpa.Spec.ScaleTargetRef),
+			MinReplicas:    hpa.Spec.MinReplicas,
+			MaxReplicas:    hpa.Spec.MaxReplicas,
+			Metrics:        make([]autoscalingv2.MetricSpec, len(hpa.Spec.Metrics)),
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+			LastScaleTime:   hpa.Status.LastScaleTime,
+			CurrentReplicas: hpa.Status.CurrentReplicas,
+			CurrentMetrics:  make([]autoscalingv2.MetricStatus, len(hpa.Status.CurrentMetrics)),
+			Conditions:      make([]autoscalingv2.HorizontalPodAutoscalerCondition, len(hpa.Status.Conditions)),
+		},
+	}
+	for i, metric := range hpa.Spec.Metrics {
+		hpaV2.Spec.Metrics[i] = metricSpecFromV2beta2(metric)
+	}
+	for i, metric := range hpa.Status.CurrentMetrics {
+		hpaV2.Status.CurrentMetrics[i] = metricStatusFromV2beta2(metric)
+	}
+	for i, condition := range hpa.Status.Conditions {
+		hpaV2.Status.Conditions[i] = autoscalingv2.HorizontalPodAutoscalerCondition{
+			Type:   autoscalingv2.HorizontalPodAutoscalerConditionType(condition.Type),
+			Status: condition.Status,
+			Reason: condition.Reason,
+		}
+	}
+	return hpaV2
+}
+
+func metricTargetFromV2beta2(target autoscalingv2beta2.MetricTarget) autoscalingv2.MetricTarget {
+	return autoscalingv2.MetricTarget{
+		Type:               autoscalingv2.MetricTargetType(target.Type),
+		Value:              target.Value,
+		AverageValue:       target.AverageValue,
+		AverageUtilization: target.AverageUtilization,
+	}
+}
+
+func metricSpecFromV2beta2(metric autoscalingv2beta2.MetricSpec) autoscalingv2.MetricSpec {
+	spec := autoscalingv2.MetricSpec{Type: autoscalingv2.MetricSourceType(metric.Type)}
+	if metric.Resource != nil {
+		spec.Resource = &autoscalingv2.ResourceMetricSource{
+			Name: metric.Resource.Name, Target: metricTargetFromV2beta2(metric.Resource.Target),
+		}
+	}
+	if metric.ContainerResource != nil {
+		spec.ContainerResource = &autoscalingv2.ContainerResourceMetricSource{
+			Name: metric.ContainerResource.Name, Container: metric.ContainerResource.Container,
+			Target: metricTargetFromV2beta2(metric.ContainerResource.Target),
+		}
+	}
+	if metric.Pods != nil {
+		spec.Pods = &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier(metric.Pods.Metric), Target: metricTargetFromV2beta2(metric.Pods.Target),
+		}
+	}
+	if metric.Object != nil {
+		spec.Object = &autoscalingv2.ObjectMetricSource{
+			DescribedObject: autoscalingv2.CrossVersionObjectReference(metric.Object.DescribedObject),
+			Metric:          autoscalingv2.MetricIdentifier(metric.Object.Metric),
+			Target:          metricTargetFromV2beta2(metric.Object.Target),
+		}
+	}
+	if metric.External != nil {
+		spec.External = &autoscalingv2.ExternalMetricSource{
+			Metric: autoscalingv2.MetricIdentifier(metric.External.Metric), Target: metricTargetFromV2beta2(metric.External.Target),
+		}
+	}
+	return spec
+}
+
+func metricStatusFromV2beta2(metric autoscalingv2beta2.MetricStatus) autoscalingv2.MetricStatus {
+	status := autoscalingv2.MetricStatus{Type: autoscalingv2.MetricSourceType(metric.Type)}
+	if metric.Resource != nil {
+		status.Resource = &autoscalingv2.ResourceMetricStatus{
+			Name: metric.Resource.Name, Current: autoscalingv2.MetricValueStatus(metric.Resource.Current),
+		}
+	}
+	if metric.ContainerResource != nil {
+		status.ContainerResource = &autoscalingv2.ContainerResourceMetricStatus{
+			Name: metric.ContainerResource.Name, Container: metric.ContainerResource.Container,
+			Current: autoscalingv2.MetricValueStatus(metric.ContainerResource.Current),
+		}
+	}
+	if metric.Pods != nil {
+		status.Pods = &autoscalingv2.PodsMetricStatus{
+			Metric: autoscalingv2.MetricIdentifier(metric.Pods.Metric), Current: autoscalingv2.MetricValueStatus(metric.Pods.Current),
+		}
+	}
+	if metric.Object != nil {
+		status.Object = &autoscalingv2.ObjectMetricStatus{
+			DescribedObject: autoscalingv2.CrossVersionObjectReference(metric.Object.DescribedObject),
+			Metric:          autoscalingv2.MetricIdentifier(metric.Object.Metric),
+			Current:         autoscalingv2.MetricValueStatus(metric.Object.Current),
+		}
+	}
+	if metric.External != nil {
+		status.External = &autoscalingv2.ExternalMetricStatus{
+			Metric: autoscalingv2.MetricIdentifier(metric.External.Metric), Current: autoscalingv2.MetricValueStatus(metric.External.Current),
+		}
+	}
+	return status
+}
+
+// formatMetricValue displays current/target of a metric like kubectl does
+func formatMetricValue(current *autoscalingv2.MetricValueStatus, target autoscalingv2.MetricTarget) string {
+	currentStr := "<unknown>"
+	if target.AverageUtilization != nil {
+		if current != nil && current.AverageUtilization != nil {
+			currentStr = fmt.Sprintf("%d%%", *current.AverageUtilization)
+		}
+		return fmt.Sprintf("%s/%d%%", currentStr, *target.AverageUtilization)
+	}
+	if target.AverageValue != nil {
+		if current != nil && current.AverageValue != nil {
+			currentStr = current.AverageValue.String()
+		}
+		return fmt.Sprintf("%s/%s", currentStr, target.AverageValue.String())
+	}
+	if target.Value != nil {
+		if current != nil && current.Value != nil {
+			currentStr = current.Value.String()
+		}
+		return fmt.Sprintf("%s/%s", currentStr, target.Value.String())
+	}
+	return fmt.Sprintf("%s/<auto>", currentStr)
+}
+
+// getHpaTargets renders each metric as name:current/target, current metrics share the spec's order
+func getHpaTargets(metrics []autoscalingv2.MetricSpec, currentMetrics []autoscalingv2.MetricStatus) []string {
+	targets := make([]string, 0, len(metrics))
+	for i, metric := range metrics {
+		var current *autoscalingv2.MetricStatus
+		if i < len(currentMetrics) && currentMetrics[i].Type == metric.Type {
+			current = &currentMetrics[i]
+		}
+		var name string
+		var currentValue *autoscalingv2.MetricValueStatus
+		var target autoscalingv2.MetricTarget
+		switch metric.Type {
+		case autoscalingv2.ResourceMetricSourceType:
+			name = string(metric.Resource.Name)
+			target = metric.Resource.Target
+			if current != nil && current.Resource != nil {
+				currentValue = &current.Resource.Current
+			}
+		case autoscalingv2.ContainerResourceMetricSourceType:
+			name = fmt.Sprintf("%s/%s", metric.ContainerResource.Container, metric.ContainerResource.Name)
+			target = metric.ContainerResource.Target
+			if current != nil && current.ContainerResource != nil {
+				currentValue = &current.ContainerResource.Current
+			}
+		case autoscalingv2.PodsMetricSourceType:
+			name = metric.Pods.Metric.Name
+			target = metric.Pods.Target
+			if current != nil && current.Pods != nil {
+				currentValue = &current.Pods.Current
+			}
+		case autoscalingv2.ObjectMetricSourceType:
+			name = metric.Object.Metric.Name
+			target = metric.Object.Target
+			if current != nil && current.Object != nil {
+				currentValue = &current.Object.Current
+			}
+		case autoscalingv2.ExternalMetricSourceType:
+			name = metric.External.Metric.Name
+			target = metric.External.Target
+			if current != nil && current.External != nil {
+				currentValue = &current.External.Current
+			}
+		default:
+			targets = append(targets, fmt.Sprintf("%s:<unknown>", metric.Type))
+			continue
+		}
+		targets = append(targets, fmt.Sprintf("%s:%s", name, formatMetricValue(currentValue, target)))
+	}
+	return targets
+}
+
+// workloadReplicas displays the ready replicas over the desired replicas of a scaled workload
+func workloadReplicas(workload K8sResource) string {
+	switch w := workload.(type) {
+	case *Deployment:
+		return fmt.Sprintf("%s/%s", w.availableReplicas, w.desiredReplicas)
+	case *StatefulSet:
+		return fmt.Sprintf("%d/%d", w.readyReplicas, w.replicas)
+	case *ReplicaSet:
+		return fmt.Sprintf("%s/%s", w.readyReplicas, w.replicas)
+	}
+	return "Unknown"
+}
+
+// Resolve finds the scaled workload and its replicas
+func (h *Hpa) Resolve(lookup StoreLookup) {
+	h.relations = nil
+	resourceName, ok := ownerResourceNames[h.targetKind]
+	if !ok {
+		h.workload = "Unknown"
+		return
+	}
+	h.workload = "NotFound"
+	if r, ok := lookup.GetResource(resourceName, ResourceKey(h.namespace, h.targetName)); ok {
+		h.workload = workloadReplicas(r)
+	}
+	h.relations = []Relation{{RelationScales, resourceName, h.namespace, h.targetName, h.workload}}
+}
+
+// Relations returns the owner of the hpa and the workload it scales
+func (h *Hpa) Relations() []Relation {
+	return append(h.ownerRelations(), h.relations...)
 }
 
 // HasChanged returns true if the resource'h dump needs to be updated
@@ -48,17 +326,22 @@ func (h *Hpa) HasChanged(k K8sResource) bool {
 
 // ToString serializes the object to strings
 func (h *Hpa) ToString() string {
-	line := strings.Join([]string{
+	lst := []string{
 		h.cluster,
 		h.namespace,
 		h.name,
 		h.reference,
-		h.targets,
+		h.workload,
+		util.JoinSlicesOrNone(h.targets, ","),
 		h.minPods,
 		h.maxPods,
 		h.currentReplicas,
+		h.ableToScale,
+		h.scalingLimited,
+		h.lastScale,
+		h.ownerString(),
 		h.resourceAge(),
 		h.labelsString(),
-	}, " ")
-	return fmt.Sprintf("%s\n", line)
+	}
+	return util.DumpLine(lst)
 }
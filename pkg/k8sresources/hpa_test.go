@@ -0,0 +1,185 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func quantityPtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestFormatMetricValue(t *testing.T) {
+	var testDatas = []struct {
+		name     string
+		current  *autoscalingv2.MetricValueStatus
+		target   autoscalingv2.MetricTarget
+		expected string
+	}{
+		{"utilization", &autoscalingv2.MetricValueStatus{AverageUtilization: int32Ptr(45)},
+			autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: int32Ptr(80)}, "45%/80%"},
+		{"utilization without current", nil,
+			autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: int32Ptr(80)}, "<unknown>/80%"},
+		{"utilization with current value only", &autoscalingv2.MetricValueStatus{AverageValue: quantityPtr("200m")},
+			autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: int32Ptr(80)}, "<unknown>/80%"},
+		{"average value", &autoscalingv2.MetricValueStatus{AverageValue: quantityPtr("200m")},
+			autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: quantityPtr("500m")}, "200m/500m"},
+		{"average value without current", &autoscalingv2.MetricValueStatus{},
+			autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: quantityPtr("1Gi")}, "<unknown>/1Gi"},
+		{"value", &autoscalingv2.MetricValueStatus{Value: quantityPtr("30")},
+			autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: quantityPtr("100")}, "30/100"},
+		{"no target", nil, autoscalingv2.MetricTarget{}, "<unknown>/<auto>"},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, formatMetricValue(testData.current, testData.target), testData.name)
+	}
+}
+
+func TestGetHpaTargets(t *testing.T) {
+	utilization := autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: int32Ptr(80)}
+	averageValue := autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: quantityPtr("10")}
+	value := autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: quantityPtr("100")}
+	metrics := []autoscalingv2.MetricSpec{
+		{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+			Name: corev1.ResourceCPU, Target: utilization}},
+		{Type: autoscalingv2.ContainerResourceMetricSourceType, ContainerResource: &autoscalingv2.ContainerResourceMetricSource{
+			Name: corev1.ResourceMemory, Container: "app", Target: averageValue}},
+		{Type: autoscalingv2.PodsMetricSourceType, Pods: &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "requests_per_second"}, Target: averageValue}},
+		{Type: autoscalingv2.ObjectMetricSourceType, Object: &autoscalingv2.ObjectMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "hits"}, Target: value}},
+		{Type: autoscalingv2.ExternalMetricSourceType, External: &autoscalingv2.ExternalMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "queue_length"}, Target: value}},
+	}
+	currentMetrics := []autoscalingv2.MetricStatus{
+		{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricStatus{
+			Name: corev1.ResourceCPU, Current: autoscalingv2.MetricValueStatus{AverageUtilization: int32Ptr(45)}}},
+		{Type: autoscalingv2.ContainerResourceMetricSourceType, ContainerResource: &autoscalingv2.ContainerResourceMetricStatus{
+			Name: corev1.ResourceMemory, Container: "app", Current: autoscalingv2.MetricValueStatus{AverageValue: quantityPtr("5")}}},
+		{Type: autoscalingv2.PodsMetricSourceType, Pods: &autoscalingv2.PodsMetricStatus{
+			Current: autoscalingv2.MetricValueStatus{AverageValue: quantityPtr("12")}}},
+		{Type: autoscalingv2.ObjectMetricSourceType, Object: &autoscalingv2.ObjectMetricStatus{
+			Current: autoscalingv2.MetricValueStatus{Value: quantityPtr("30")}}},
+		{Type: autoscalingv2.ExternalMetricSourceType, External: &autoscalingv2.ExternalMetricStatus{
+			Current: autoscalingv2.MetricValueStatus{Value: quantityPtr("250")}}},
+	}
+
+	var testDatas = []struct {
+		name           string
+		currentMetrics []autoscalingv2.MetricStatus
+		expected       []string
+	}{
+		{"all sources", currentMetrics, []string{
+			"cpu:45%/80%", "app/memory:5/10", "requests_per_second:12/10", "hits:30/100", "queue_length:250/100",
+		}},
+		{"missing current status", nil, []string{
+			"cpu:<unknown>/80%", "app/memory:<unknown>/10", "requests_per_second:<unknown>/10",
+			"hits:<unknown>/100", "queue_length:<unknown>/100",
+		}},
+		{"partial current status", currentMetrics[:2], []string{
+			"cpu:45%/80%", "app/memory:5/10", "requests_per_second:<unknown>/10",
+			"hits:<unknown>/100", "queue_length:<unknown>/100",
+		}},
+		{"current status of another type", currentMetrics[1:], []string{
+			"cpu:<unknown>/80%", "app/memory:<unknown>/10", "requests_per_second:<unknown>/10",
+			"hits:<unknown>/100", "queue_length:<unknown>/100",
+		}},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, getHpaTargets(metrics, testData.currentMetrics), testData.name)
+	}
+	assert.Equal(t, []string{"Unknown:<unknown>"}, getHpaTargets([]autoscalingv2.MetricSpec{{Type: "Unknown"}}, nil))
+}
+
+func TestHpaFromV2beta2(t *testing.T) {
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{Kind: "Deployment", Name: "web"},
+			MinReplicas:    int32Ptr(2),
+			MaxReplicas:    10,
+			Metrics: []autoscalingv2beta2.MetricSpec{
+				{Type: autoscalingv2beta2.ResourceMetricSourceType, Resource: &autoscalingv2beta2.ResourceMetricSource{
+					Name:   corev1.ResourceCPU,
+					Target: autoscalingv2beta2.MetricTarget{Type: autoscalingv2beta2.UtilizationMetricType, AverageUtilization: int32Ptr(80)},
+				}},
+				{Type: autoscalingv2beta2.ExternalMetricSourceType, External: &autoscalingv2beta2.ExternalMetricSource{
+					Metric: autoscalingv2beta2.MetricIdentifier{Name: "queue_length"},
+					Target: autoscalingv2beta2.MetricTarget{Type: autoscalingv2beta2.AverageValueMetricType, AverageValue: quantityPtr("30")},
+				}},
+			},
+		},
+		Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: 3,
+			CurrentMetrics: []autoscalingv2beta2.MetricStatus{
+				{Type: autoscalingv2beta2.ResourceMetricSourceType, Resource: &autoscalingv2beta2.ResourceMetricStatus{
+					Name:    corev1.ResourceCPU,
+					Current: autoscalingv2beta2.MetricValueStatus{AverageUtilization: int32Ptr(60)},
+				}},
+			},
+			Conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				{Type: autoscalingv2beta2.AbleToScale, Status: corev1.ConditionTrue, Reason: "ReadyForNewScale"},
+			},
+		},
+	}
+	h := NewHpaFromRuntime(hpa, CtorConfig{}).(*Hpa)
+	assert.Equal(t, "Deployment/web", h.reference)
+	assert.Equal(t, "2", h.minPods)
+	assert.Equal(t, "10", h.maxPods)
+	assert.Equal(t, "3", h.currentReplicas)
+	assert.Equal(t, []string{"cpu:60%/80%", "queue_length:<unknown>/30"}, h.targets)
+	assert.Equal(t, "ReadyForNewScale", h.ableToScale)
+}
+
+func TestHpaFromV2beta2Metrics(t *testing.T) {
+	target := autoscalingv2beta2.MetricTarget{Type: autoscalingv2beta2.AverageValueMetricType, AverageValue: quantityPtr("10")}
+	current := autoscalingv2beta2.MetricValueStatus{AverageValue: quantityPtr("5")}
+	object := autoscalingv2beta2.CrossVersionObjectReference{Kind: "Ingress", Name: "web"}
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			Metrics: []autoscalingv2beta2.MetricSpec{
+				{Type: autoscalingv2beta2.ContainerResourceMetricSourceType, ContainerResource: &autoscalingv2beta2.ContainerResourceMetricSource{
+					Name: corev1.ResourceMemory, Container: "app", Target: target,
+				}},
+				{Type: autoscalingv2beta2.PodsMetricSourceType, Pods: &autoscalingv2beta2.PodsMetricSource{
+					Metric: autoscalingv2beta2.MetricIdentifier{Name: "requests_per_second"}, Target: target,
+				}},
+				{Type: autoscalingv2beta2.ObjectMetricSourceType, Object: &autoscalingv2beta2.ObjectMetricSource{
+					DescribedObject: object, Metric: autoscalingv2beta2.MetricIdentifier{Name: "hits"}, Target: target,
+				}},
+			},
+		},
+		Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+			CurrentMetrics: []autoscalingv2beta2.MetricStatus{
+				{Type: autoscalingv2beta2.ContainerResourceMetricSourceType, ContainerResource: &autoscalingv2beta2.ContainerResourceMetricStatus{
+					Name: corev1.ResourceMemory, Container: "app", Current: current,
+				}},
+				{Type: autoscalingv2beta2.PodsMetricSourceType, Pods: &autoscalingv2beta2.PodsMetricStatus{
+					Metric: autoscalingv2beta2.MetricIdentifier{Name: "requests_per_second"}, Current: current,
+				}},
+				{Type: autoscalingv2beta2.ObjectMetricSourceType, Object: &autoscalingv2beta2.ObjectMetricStatus{
+					DescribedObject: object, Metric: autoscalingv2beta2.MetricIdentifier{Name: "hits"}, Current: current,
+				}},
+			},
+			Conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				{Type: autoscalingv2beta2.ScalingLimited, Status: corev1.ConditionTrue, Reason: "TooManyReplicas"},
+			},
+		},
+	}
+	h := NewHpaFromRuntime(hpa, CtorConfig{}).(*Hpa)
+	assert.Equal(t, []string{"app/memory:5/10", "requests_per_second:5/10", "hits:5/10"}, h.targets)
+	assert.Equal(t, "TooManyReplicas", h.scalingLimited)
+	hpaV2 := hpaFromV2beta2(hpa)
+	assert.Equal(t, "Ingress", hpaV2.Spec.Metrics[2].Object.DescribedObject.Kind)
+}
@@ -1,16 +1,31 @@
 package k8sresources
 
 import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
 	betav1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"kubectlfzf/pkg/util"
 )
 
-const IngressHeader = "Cluster Namespace Name Address Age Labels\n"
+const IngressHeader = "Cluster Namespace Name Hosts Address Backends Owner Age Labels\n"
+
+// ingressBackend is a service targeted by an ingress
+type ingressBackend struct {
+	service string
+	port    string
+}
 
 // Ingress is the summary of a kubernetes ingress
 type Ingress struct {
 	ResourceMeta
-	address []string
+	hosts     []string
+	address   []string
+	backends  []ingressBackend
+	resolved  []string
+	relations []Relation
 }
 
 // NewIngressFromRuntime builds a pod from informer result
@@ -22,11 +37,110 @@ func NewIngressFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 
 // FromRuntime builds object from the informer's result
 func (ingress *Ingress) FromRuntime(obj interface{}, config CtorConfig) {
-	ingressFromRuntime := obj.(*betav1.Ingress)
-	ingress.FromObjectMeta(ingressFromRuntime.ObjectMeta, config)
-	for _, lb := range ingressFromRuntime.Status.LoadBalancer.Ingress {
-		ingress.address = append(ingress.address, lb.Hostname)
+	switch ingressFromRuntime := obj.(type) {
+	case *networkingv1.Ingress:
+		ingress.FromObjectMeta(ingressFromRuntime.ObjectMeta, config)
+		ingress.fromStatus(ingressFromRuntime.Status.LoadBalancer)
+		spec := ingressFromRuntime.Spec
+		ingress.addV1Backend(spec.DefaultBackend)
+		for _, rule := range spec.Rules {
+			ingress.addHost(rule.Host)
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				ingress.addV1Backend(&path.Backend)
+			}
+		}
+	case *betav1.Ingress:
+		ingress.FromObjectMeta(ingressFromRuntime.ObjectMeta, config)
+		ingress.fromStatus(ingressFromRuntime.Status.LoadBalancer)
+		spec := ingressFromRuntime.Spec
+		ingress.addBetaBackend(spec.Backend)
+		for _, rule := range spec.Rules {
+			ingress.addHost(rule.Host)
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				ingress.addBetaBackend(&path.Backend)
+			}
+		}
+	}
+}
+
+func (ingress *Ingress) fromStatus(status corev1.LoadBalancerStatus) {
+	for _, lb := range status.Ingress {
+		if lb.Hostname != "" {
+			ingress.address = append(ingress.address, lb.Hostname)
+		}
+		if lb.IP != "" {
+			ingress.address = append(ingress.address, lb.IP)
+		}
+	}
+}
+
+func (ingress *Ingress) addHost(host string) {
+	if host == "" {
+		host = "*"
+	}
+	for _, h := range ingress.hosts {
+		if h == host {
+			return
+		}
+	}
+	ingress.hosts = append(ingress.hosts, host)
+}
+
+func (ingress *Ingress) addBackend(backend ingressBackend) {
+	for _, b := range ingress.backends {
+		if b == backend {
+			return
+		}
 	}
+	ingress.backends = append(ingress.backends, backend)
+}
+
+func (ingress *Ingress) addV1Backend(backend *networkingv1.IngressBackend) {
+	if backend == nil || backend.Service == nil {
+		return
+	}
+	port := backend.Service.Port.Name
+	if port == "" {
+		port = strconv.Itoa(int(backend.Service.Port.Number))
+	}
+	ingress.addBackend(ingressBackend{backend.Service.Name, port})
+}
+
+func (ingress *Ingress) addBetaBackend(backend *betav1.IngressBackend) {
+	if backend == nil || backend.ServiceName == "" {
+		return
+	}
+	ingress.addBackend(ingressBackend{backend.ServiceName, backend.ServicePort.String()})
+}
+
+// Resolve finds the backend services and the readiness of their pods
+func (ingress *Ingress) Resolve(lookup StoreLookup) {
+	ingress.resolved = make([]string, len(ingress.backends))
+	ingress.relations = make([]Relation, len(ingress.backends))
+	for k, backend := range ingress.backends {
+		details := "NotFound"
+		r, ok := lookup.GetResource("services", ResourceKey(ingress.namespace, backend.service))
+		if ok {
+			details = "Unknown"
+			pods, ok := selectPods(lookup, ingress.namespace, r.(*Service).selector)
+			if ok {
+				details = podsReadyString(pods)
+			}
+		}
+		ingress.resolved[k] = fmt.Sprintf("%s:%s(%s)", backend.service, backend.port, details)
+		ingress.relations[k] = Relation{RelationBackend, "services", ingress.namespace, backend.service, details}
+	}
+}
+
+// Relations returns the owner of the ingress and the services it targets
+func (ingress *Ingress) Relations() []Relation {
+	return append(ingress.ownerRelations(), ingress.relations...)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -41,7 +155,10 @@ func (ingress *Ingress) ToString() string {
 		ingress.cluster,
 		ingress.namespace,
 		ingress.name,
+		util.JoinSlicesOrNone(ingress.hosts, ","),
 		addressList,
+		util.JoinSlicesOrNone(ingress.resolved, ","),
+		ingress.ownerString(),
 		ingress.resourceAge(),
 		ingress.labelsString(),
 	}
@@ -2,19 +2,30 @@ package k8sresources
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/golang/glog"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
 	"kubectlfzf/pkg/util"
 )
 
 // JobHeader is the headers for job files
-const JobHeader = "Cluster Namespace Name Completions Containers Age Labels\n"
+const JobHeader = "Cluster Namespace Name Completions Active Succeeded Failed Condition StartTime Duration CronJob Containers Owner Age Labels\n"
 
 // Job is the summary of a kubernetes Job
 type Job struct {
 	ResourceMeta
 	completions string
+	active      string
+	succeeded   string
+	failed      string
+	condition   string
+	startTime   string
+	duration    string
+	cronJob     string
 	containers  []string
 }
 
@@ -37,6 +48,38 @@ func (j *Job) FromRuntime(obj interface{}, config CtorConfig) {
 		successful := int(job.Status.Succeeded)
 		j.completions = fmt.Sprintf("%d/%d", successful, desired)
 	}
+	j.active = strconv.Itoa(int(job.Status.Active))
+	j.succeeded = strconv.Itoa(int(job.Status.Succeeded))
+	j.failed = strconv.Itoa(int(job.Status.Failed))
+
+	j.condition = ""
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		if condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed {
+			j.condition = string(condition.Type)
+			if condition.Reason != "" {
+				j.condition = fmt.Sprintf("%s:%s", condition.Type, condition.Reason)
+			}
+		}
+	}
+
+	j.startTime = ""
+	j.duration = ""
+	if job.Status.StartTime != nil {
+		j.startTime = util.TimeToAge(job.Status.StartTime.Time)
+		end := time.Now()
+		if job.Status.CompletionTime != nil {
+			end = job.Status.CompletionTime.Time
+		}
+		j.duration = duration.HumanDuration(end.Sub(job.Status.StartTime.Time))
+	}
+
+	j.cronJob = ""
+	if j.ownerKind == "CronJob" {
+		j.cronJob = j.ownerName
+	}
 
 	spec := job.Spec.Template.Spec
 	containers := spec.Containers
@@ -59,7 +102,15 @@ func (j *Job) ToString() string {
 		j.namespace,
 		j.name,
 		j.completions,
+		j.active,
+		j.succeeded,
+		j.failed,
+		j.condition,
+		j.startTime,
+		j.duration,
+		j.cronJob,
 		util.JoinSlicesOrNone(j.containers, ","),
+		j.ownerString(),
 		j.resourceAge(),
 		j.labelsString(),
 	}
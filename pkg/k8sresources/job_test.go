@@ -0,0 +1,63 @@
+package k8sresources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJobFromRuntime(t *testing.T) {
+	start := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	end := metav1.NewTime(start.Add(90 * time.Second))
+	var testDatas = []struct {
+		name               string
+		completions        *int32
+		status             batchv1.JobStatus
+		expectedCompletion string
+		expectedCondition  string
+		expectedStart      string
+		expectedDuration   string
+	}{
+		{"not started", int32Ptr(1), batchv1.JobStatus{}, "0/1", "", "", ""},
+		{"parallel without completions", nil, batchv1.JobStatus{Active: 2, StartTime: &start}, "-", "", "02:00", "120m"},
+		{"complete", int32Ptr(3), batchv1.JobStatus{
+			Succeeded: 3, StartTime: &start, CompletionTime: &end,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+		}, "3/3", "Complete", "02:00", "90s"},
+		{"failed with reason", int32Ptr(1), batchv1.JobStatus{
+			Failed: 6, StartTime: &start,
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobSuspended, Status: corev1.ConditionTrue},
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+			},
+		}, "0/1", "Failed:BackoffLimitExceeded", "02:00", "120m"},
+		{"condition not true", int32Ptr(1), batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionFalse, Reason: "BackoffLimitExceeded"}},
+		}, "0/1", "", "", ""},
+	}
+	for _, testData := range testDatas {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "ns1"},
+			Spec:       batchv1.JobSpec{Completions: testData.completions},
+			Status:     testData.status,
+		}
+		j := NewJobFromRuntime(job, CtorConfig{}).(*Job)
+		assert.Equal(t, testData.expectedCompletion, j.completions, testData.name)
+		assert.Equal(t, testData.expectedCondition, j.condition, testData.name)
+		assert.Equal(t, testData.expectedStart, j.startTime, testData.name)
+		assert.Equal(t, testData.expectedDuration, j.duration, testData.name)
+	}
+}
+
+func TestJobCronJob(t *testing.T) {
+	controller := true
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name: "backup-27600000", Namespace: "ns1",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "backup", Controller: &controller}},
+	}}
+	assert.Equal(t, "backup", NewJobFromRuntime(job, CtorConfig{}).(*Job).cronJob)
+}
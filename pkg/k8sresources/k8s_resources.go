@@ -1,10 +1,14 @@
 package k8sresources
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"kubectlfzf/pkg/util"
@@ -17,6 +21,45 @@ type K8sResource interface {
 	FromRuntime(obj interface{}, config CtorConfig)
 }
 
+// StoreLookup gives access to the summaries of the other watched resources
+type StoreLookup interface {
+	GetResource(resourceName string, key string) (K8sResource, bool)
+	// GetIndexedResources returns nil if resourceName is not watched
+	GetIndexedResources(resourceName string, indexName string, value string) []K8sResource
+}
+
+// Indexable is implemented by resources other resources need to find by a given value
+type Indexable interface {
+	// IndexValues returns the values of the resource for each index name
+	IndexValues() map[string][]string
+}
+
+// Index names used to find resources from other resources
+const (
+	// NodeIndex indexes resources by the node they are scheduled on
+	NodeIndex = "node"
+	// NamespaceIndex indexes resources by their namespace
+	NamespaceIndex = "namespace"
+	// ConfigMapIndex indexes pods by the configmaps they reference
+	ConfigMapIndex = "configmap"
+	// SecretIndex indexes pods by the secrets they reference
+	SecretIndex = "secret"
+	// ServiceAccountIndex indexes pods by their service account
+	ServiceAccountIndex = "serviceaccount"
+	// ClaimIndex indexes pods by the persistent volume claims they mount
+	ClaimIndex = "claim"
+)
+
+// Resolvable is implemented by resources with columns computed from other watched resources
+type Resolvable interface {
+	Resolve(lookup StoreLookup)
+}
+
+// ResourceKey builds the key identifying a resource in its store
+func ResourceKey(namespace string, name string) string {
+	return fmt.Sprintf("%s_%s", namespace, name)
+}
+
 // ResourceMeta is the generic information of a k8s entity
 type ResourceMeta struct {
 	name         string
@@ -24,6 +67,8 @@ type ResourceMeta struct {
 	cluster      string
 	labels       map[string]string
 	creationTime time.Time
+	ownerKind    string
+	ownerName    string
 }
 
 // FromObjectMeta copies meta information to the object
@@ -33,29 +78,118 @@ func (r *ResourceMeta) FromObjectMeta(meta metav1.ObjectMeta, config CtorConfig)
 	r.cluster = config.Cluster
 	r.labels = meta.Labels
 	r.creationTime = meta.CreationTimestamp.Time
+	r.setOwner(meta.OwnerReferences)
+}
+
+// setOwner keeps the controller owner, or the first owner if none is flagged as controller
+func (r *ResourceMeta) setOwner(ownerReferences []metav1.OwnerReference) {
+	for _, owner := range ownerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			r.ownerKind = owner.Kind
+			r.ownerName = owner.Name
+			return
+		}
+	}
+	if len(ownerReferences) > 0 {
+		r.ownerKind = ownerReferences[0].Kind
+		r.ownerName = ownerReferences[0].Name
+	}
 }
 
 // FromDynamicMeta copies meta information to the object
-func (r *ResourceMeta) FromDynamicMeta(u *unstructured.Unstructured, config CtorConfig) {
-	metadata := u.Object["metadata"].(map[string]interface{})
-	r.name = metadata["name"].(string)
-	r.namespace = metadata["namespace"].(string)
+func (r *ResourceMeta) FromDynamicMeta(u *unstructured.Unstructured, config CtorConfig) error {
+	r.name = u.GetName()
+	r.namespace = u.GetNamespace()
 	r.cluster = config.Cluster
+	r.creationTime = u.GetCreationTimestamp().Time
 	var err error
 	var found bool
 	r.labels, found, err = unstructured.NestedStringMap(u.Object, "metadata", "labels")
-	util.FatalIf(err)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading labels of %s/%s", r.namespace, r.name)
+	}
 	if !found {
 		glog.V(3).Infof("metadata.labels was not found in %#v", u.Object)
 	}
-	r.creationTime, err = time.Parse(time.RFC3339, metadata["creationTimestamp"].(string))
-	util.FatalIf(err)
+
+	var ownerReferences []metav1.OwnerReference
+	owners, _, _ := unstructured.NestedSlice(u.Object, "metadata", "ownerReferences")
+	for _, o := range owners {
+		owner, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ownerReference := metav1.OwnerReference{}
+		ownerReference.Kind, _, _ = unstructured.NestedString(owner, "kind")
+		ownerReference.Name, _, _ = unstructured.NestedString(owner, "name")
+		controller, found, _ := unstructured.NestedBool(owner, "controller")
+		if found {
+			ownerReference.Controller = &controller
+		}
+		ownerReferences = append(ownerReferences, ownerReference)
+	}
+	r.setOwner(ownerReferences)
+	return nil
 }
 
 func (r *ResourceMeta) resourceAge() string {
 	return util.TimeToAge(r.creationTime)
 }
 
+func (r *ResourceMeta) getMeta() *ResourceMeta {
+	return r
+}
+
+func (r *ResourceMeta) ownerString() string {
+	if r.ownerKind == "" {
+		return "None"
+	}
+	return fmt.Sprintf("%s/%s", r.ownerKind, r.ownerName)
+}
+
+type metaAccessor interface {
+	getMeta() *ResourceMeta
+}
+
+// ownerResourceNames maps owner kinds to the name of the store watching them
+var ownerResourceNames = map[string]string{
+	"ReplicaSet":  "replicasets",
+	"Deployment":  "deployments",
+	"StatefulSet": "statefulsets",
+	"DaemonSet":   "daemonsets",
+	"Job":         "jobs",
+	"CronJob":     "cronjobs",
+}
+
+// maxOwnerDepth protects against ownership cycles
+const maxOwnerDepth = 10
+
+// resolveController follows the owner chain through the stores and returns the top-level controller
+func (r *ResourceMeta) resolveController(lookup StoreLookup) string {
+	kind := r.ownerKind
+	name := r.ownerName
+	for i := 0; i < maxOwnerDepth && kind != ""; i++ {
+		resourceName, ok := ownerResourceNames[kind]
+		if !ok {
+			break
+		}
+		owner, ok := lookup.GetResource(resourceName, ResourceKey(r.namespace, name))
+		if !ok {
+			break
+		}
+		ownerMeta := owner.(metaAccessor).getMeta()
+		if ownerMeta.ownerKind == "" {
+			break
+		}
+		kind = ownerMeta.ownerKind
+		name = ownerMeta.ownerName
+	}
+	if kind == "" {
+		return "None"
+	}
+	return fmt.Sprintf("%s/%s", kind, name)
+}
+
 // ExcludedLabels is a list of excluded label/selector from the dump
 var ExcludedLabels = map[string]string{"pod-template-generation": "",
 	"app.kubernetes.io/name": "", "controller-revision-hash": "",
@@ -71,3 +205,69 @@ func (r *ResourceMeta) labelsString() string {
 	sort.Strings(els)
 	return util.JoinSlicesOrNone(els, ",")
 }
+
+// getImages returns the deduplicated list of images used by containers
+func getImages(containers []corev1.Container) []string {
+	images := make([]string, 0)
+	imageSet := make(map[string]bool)
+	for _, v := range containers {
+		if !imageSet[v.Image] {
+			imageSet[v.Image] = true
+			images = append(images, v.Image)
+		}
+	}
+	return images
+}
+
+// generationStatus flags a resource whose last spec change wasn't observed by its controller yet
+func generationStatus(generation int64, observedGeneration int64) string {
+	if generation != observedGeneration {
+		return fmt.Sprintf("stale:%d/%d", observedGeneration, generation)
+	}
+	return strconv.FormatInt(generation, 10)
+}
+
+// maxDisplayedKeys is the maximum number of data keys displayed for configmaps and secrets
+const maxDisplayedKeys = 20
+
+// getSortedKeys returns the sorted key names of data maps, values are never accessed
+func getSortedKeys(keys ...[]string) []string {
+	res := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, lst := range keys {
+		for _, key := range lst {
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, key)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// keysString joins the key names with a cap on the number of keys and the length
+func keysString(keys []string, metadataOnly bool) string {
+	if metadataOnly {
+		return "Unknown"
+	}
+	return util.TruncateString(util.JoinSlicesWithMaxOrNone(keys, maxDisplayedKeys, ","), 300)
+}
+
+// maxDisplayedUsers is the maximum number of pods displayed in UsedBy columns
+const maxDisplayedUsers = 10
+
+// resolveUsedBy returns the names of the pods referencing the resource through the given index.
+// Unknown is returned when pods are not watched as the resource can't be considered unused
+func (r *ResourceMeta) resolveUsedBy(lookup StoreLookup, indexName string) string {
+	pods := lookup.GetIndexedResources("pods", indexName, ResourceKey(r.namespace, r.name))
+	if pods == nil {
+		return "Unknown"
+	}
+	names := make([]string, len(pods))
+	for k, v := range pods {
+		names[k] = v.(*Pod).name
+	}
+	sort.Strings(names)
+	return util.JoinSlicesWithMaxOrNone(names, maxDisplayedUsers, ",")
+}
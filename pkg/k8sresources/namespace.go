@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"kubectlfzf/pkg/util"
 )
 
 const NamespaceHeader = "Cluster Name Age Labels\n"
@@ -29,7 +30,8 @@ func (c *Namespace) FromRuntime(obj interface{}, config CtorConfig) {
 
 // HasChanged returns true if the resource's dump needs to be updated
 func (c *Namespace) HasChanged(k K8sResource) bool {
-	return false
+	oldNamespace := k.(*Namespace)
+	return !util.StringMapsEqual(c.labels, oldNamespace.labels)
 }
 
 // ToString serializes the object to strings
@@ -3,25 +3,44 @@ package k8sresources
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"kubectlfzf/pkg/util"
 )
 
 // NodeHeader is the header line of csv result
-const NodeHeader = "Cluster Name Roles Status InstanceType Zone InternalIp Taints InstanceID Age Labels\n"
+const NodeHeader = "Cluster Name Roles Status Conditions InstanceType Zone InternalIp Taints InstanceID KubeletVersion OsImage ContainerRuntime Architecture CpuCapacity CpuAllocatable CpuRequests MemCapacity MemAllocatable MemRequests PodCapacity PodAllocatable PodCount Age Labels\n"
 
 // Node is the summary of a kubernetes node
 type Node struct {
 	ResourceMeta
 	roles        []string
 	status       string
+	conditions   []string
 	instanceType string
 	zone         string
 	instanceID   string
 	internalIP   string
 	taints       []string
+
+	kubeletVersion   string
+	osImage          string
+	containerRuntime string
+	architecture     string
+
+	cpuCapacity       int64 // In millicores
+	cpuAllocatable    int64 // In millicores
+	memoryCapacity    int64 // In bytes
+	memoryAllocatable int64 // In bytes
+	podCapacity       int64
+	podAllocatable    int64
+
+	cpuRequests    int64 // In millicores
+	memoryRequests int64 // In bytes
+	podCount       int
 }
 
 // NewNodeFromRuntime builds a k8sresoutce from informer result
@@ -32,14 +51,38 @@ func NewNodeFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 }
 
 func getNodeStatus(node *corev1.Node) string {
+	status := "Ready"
 	for _, condition := range node.Status.Conditions {
 		if condition.Type == "Ready" {
 			if condition.Status != "True" {
-				return condition.Reason
+				status = condition.Reason
+			}
+		}
+	}
+	if node.Spec.Unschedulable {
+		status = fmt.Sprintf("%s,SchedulingDisabled", status)
+	}
+	return status
+}
+
+// nodeProblemConditions are the conditions that are only reported when true
+var nodeProblemConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+}
+
+func getNodeConditions(node *corev1.Node) []string {
+	conditions := make([]string, 0)
+	for _, conditionType := range nodeProblemConditions {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+				conditions = append(conditions, string(condition.Type))
 			}
 		}
 	}
-	return "Ready"
+	return conditions
 }
 
 // FromRuntime builds object from the informer's result
@@ -62,6 +105,13 @@ func (n *Node) FromRuntime(obj interface{}, config CtorConfig) {
 	}
 
 	n.status = getNodeStatus(node)
+	n.conditions = getNodeConditions(node)
+
+	nodeInfo := node.Status.NodeInfo
+	n.kubeletVersion = nodeInfo.KubeletVersion
+	n.osImage = strings.ReplaceAll(nodeInfo.OSImage, " ", "_")
+	n.containerRuntime = nodeInfo.ContainerRuntimeVersion
+	n.architecture = nodeInfo.Architecture
 
 	n.taints = make([]string, 0)
 	for _, t := range node.Spec.Taints {
@@ -82,27 +132,111 @@ func (n *Node) FromRuntime(obj interface{}, config CtorConfig) {
 		}
 	}
 	sort.Strings(n.roles)
+
+	n.cpuCapacity = node.Status.Capacity.Cpu().MilliValue()
+	n.memoryCapacity = node.Status.Capacity.Memory().Value()
+	n.podCapacity = node.Status.Capacity.Pods().Value()
+	n.cpuAllocatable = node.Status.Allocatable.Cpu().MilliValue()
+	n.memoryAllocatable = node.Status.Allocatable.Memory().Value()
+	n.podAllocatable = node.Status.Allocatable.Pods().Value()
+}
+
+// Resolve sums the requests of the non terminated pods scheduled on the node
+func (n *Node) Resolve(lookup StoreLookup) {
+	n.cpuRequests = 0
+	n.memoryRequests = 0
+	n.podCount = 0
+	for _, r := range lookup.GetIndexedResources("pods", NodeIndex, n.name) {
+		pod := r.(*Pod)
+		if pod.terminated {
+			continue
+		}
+		n.cpuRequests += pod.cpuRequests
+		n.memoryRequests += pod.memoryRequests
+		n.podCount++
+	}
+}
+
+// formatCPU displays millicores like kubectl does, e.g. 250m or 2
+func formatCPU(milliCPU int64) string {
+	return resource.NewMilliQuantity(milliCPU, resource.DecimalSI).String()
+}
+
+// formatMemory displays bytes with the closest binary unit, e.g. 15.6Gi
+func formatMemory(bytes int64) string {
+	units := []string{"Ki", "Mi", "Gi", "Ti"}
+	value := float64(bytes)
+	unit := ""
+	for _, u := range units {
+		if value < 1024 {
+			break
+		}
+		value /= 1024
+		unit = u
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + unit
+}
+
+// formatUsage displays a requested amount with its ratio of the allocatable amount
+func formatUsage(requested string, value int64, allocatable int64) string {
+	if allocatable == 0 {
+		return requested
+	}
+	return fmt.Sprintf("%s(%d%%)", requested, value*100/allocatable)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
 func (n *Node) HasChanged(k K8sResource) bool {
-	return true
+	oldNode := k.(*Node)
+	return (n.status != oldNode.status ||
+		!util.StringSlicesEqual(n.conditions, oldNode.conditions) ||
+		!util.StringSlicesEqual(n.roles, oldNode.roles) ||
+		!util.StringSlicesEqual(n.taints, oldNode.taints) ||
+		!util.StringMapsEqual(n.labels, oldNode.labels) ||
+		n.instanceType != oldNode.instanceType ||
+		n.zone != oldNode.zone ||
+		n.internalIP != oldNode.internalIP ||
+		n.instanceID != oldNode.instanceID ||
+		n.kubeletVersion != oldNode.kubeletVersion ||
+		n.osImage != oldNode.osImage ||
+		n.containerRuntime != oldNode.containerRuntime ||
+		n.architecture != oldNode.architecture ||
+		n.cpuCapacity != oldNode.cpuCapacity ||
+		n.cpuAllocatable != oldNode.cpuAllocatable ||
+		n.memoryCapacity != oldNode.memoryCapacity ||
+		n.memoryAllocatable != oldNode.memoryAllocatable ||
+		n.podCapacity != oldNode.podCapacity ||
+		n.podAllocatable != oldNode.podAllocatable)
 }
 
 // ToString serializes the object to strings
 func (n *Node) ToString() string {
-	line := strings.Join([]string{
+	lst := []string{
 		n.cluster,
 		n.name,
 		util.JoinSlicesOrNone(n.roles, ","),
 		n.status,
+		util.JoinSlicesOrNone(n.conditions, ","),
 		n.instanceType,
 		n.zone,
 		n.internalIP,
 		util.JoinSlicesOrNone(n.taints, ","),
 		n.instanceID,
+		n.kubeletVersion,
+		n.osImage,
+		n.containerRuntime,
+		n.architecture,
+		formatCPU(n.cpuCapacity),
+		formatCPU(n.cpuAllocatable),
+		formatUsage(formatCPU(n.cpuRequests), n.cpuRequests, n.cpuAllocatable),
+		formatMemory(n.memoryCapacity),
+		formatMemory(n.memoryAllocatable),
+		formatUsage(formatMemory(n.memoryRequests), n.memoryRequests, n.memoryAllocatable),
+		strconv.FormatInt(n.podCapacity, 10),
+		strconv.FormatInt(n.podAllocatable, 10),
+		formatUsage(strconv.Itoa(n.podCount), int64(n.podCount), n.podAllocatable),
 		n.resourceAge(),
 		n.labelsString(),
-	}, " ")
-	return fmt.Sprintf("%s\n", line)
+	}
+	return util.DumpLine(lst)
 }
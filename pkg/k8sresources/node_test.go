@@ -0,0 +1,114 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode() *corev1.Node {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{
+			Capacity:    resources.DeepCopy(),
+			Allocatable: resources.DeepCopy(),
+			NodeInfo:    corev1.NodeSystemInfo{Architecture: "amd64"},
+		},
+	}
+}
+
+func TestNodeHasChanged(t *testing.T) {
+	var testDatas = []struct {
+		name    string
+		update  func(*corev1.Node)
+		changed bool
+	}{
+		{"unchanged", func(*corev1.Node) {}, false},
+		{"cpu capacity", func(n *corev1.Node) { n.Status.Capacity[corev1.ResourceCPU] = resource.MustParse("8") }, true},
+		{"memory capacity", func(n *corev1.Node) { n.Status.Capacity[corev1.ResourceMemory] = resource.MustParse("32Gi") }, true},
+		{"pod capacity", func(n *corev1.Node) { n.Status.Capacity[corev1.ResourcePods] = resource.MustParse("250") }, true},
+		{"architecture", func(n *corev1.Node) { n.Status.NodeInfo.Architecture = "arm64" }, true},
+		{"instance id", func(n *corev1.Node) { n.Spec.ProviderID = "aws:///eu-west-1a/i-1234" }, true},
+	}
+	for _, testData := range testDatas {
+		oldNode := NewNodeFromRuntime(testNode(), CtorConfig{})
+		node := testNode()
+		testData.update(node)
+		newNode := NewNodeFromRuntime(node, CtorConfig{})
+		assert.Equal(t, testData.changed, newNode.HasChanged(oldNode), testData.name)
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	var testDatas = []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0"},
+		{1023, "1023"},
+		{1024, "1Ki"},
+		{1536, "1.5Ki"},
+		{128 << 20, "128Mi"},
+		{16 << 30, "16Gi"},
+		{16*(1<<30) + 600<<20, "16.6Gi"},
+		{3 << 40, "3Ti"},
+		{2048 << 40, "2048Ti"},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, formatMemory(testData.bytes))
+	}
+}
+
+func TestFormatUsage(t *testing.T) {
+	var testDatas = []struct {
+		requested   string
+		value       int64
+		allocatable int64
+		expected    string
+	}{
+		{"250m", 250, 1000, "250m(25%)"},
+		{"0", 0, 1000, "0(0%)"},
+		{"5", 5, 3, "5(166%)"},
+		{"1", 1, 3, "1(33%)"},
+		{"250m", 250, 0, "250m"},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, formatUsage(testData.requested, testData.value, testData.allocatable))
+	}
+}
+
+func TestNodeResolve(t *testing.T) {
+	n := NewNodeFromRuntime(testNode(), CtorConfig{}).(*Node)
+	pods := []K8sResource{}
+	for _, phase := range []corev1.PodPhase{corev1.PodRunning, corev1.PodPending, corev1.PodSucceeded, corev1.PodFailed} {
+		pod := testPod()
+		pod.Status.Phase = phase
+		pod.Spec.Containers[0].Resources.Requests = requests("1", "2Gi")
+		pods = append(pods, NewPodFromRuntime(pod, CtorConfig{}))
+	}
+	n.Resolve(fakeLookup{pods})
+	assert.Equal(t, int64(2000), n.cpuRequests)
+	assert.Equal(t, int64(4<<30), n.memoryRequests)
+	assert.Equal(t, 2, n.podCount)
+}
+
+// fakeLookup returns the same resources for every index lookup
+type fakeLookup struct {
+	resources []K8sResource
+}
+
+func (f fakeLookup) GetResource(resourceName string, key string) (K8sResource, bool) {
+	return nil, false
+}
+
+func (f fakeLookup) GetIndexedResources(resourceName string, indexName string, value string) []K8sResource {
+	return f.resources
+}
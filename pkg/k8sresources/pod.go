@@ -2,6 +2,7 @@ package k8sresources
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"kubectlfzf/pkg/util"
@@ -11,7 +12,7 @@ import (
 )
 
 // PodHeader is the header for pod files
-const PodHeader = "Cluster Namespace Name PodIp HostIp NodeName Phase QOSClass Containers Tolerations Claims Age Labels FieldSelectors\n"
+const PodHeader = "Cluster Namespace Name PodIp HostIp NodeName Phase Ready Restarts QOSClass Containers Images LastTermination Tolerations Claims Owner Controller Age Labels FieldSelectors\n"
 
 // Pod is the summary of a kubernetes pod
 type Pod struct {
@@ -21,11 +22,24 @@ type Pod struct {
 	nodeName       string
 	tolerations    []string
 	containers     []string
+	images         []string
+	ready          string
+	podReady       bool
+	restarts       int
+	terminations   []string
 	claims         []string
+	claimNames     []string
 	phase          string
 	fieldSelectors string
 	qosClass       string
 	resource       string
+	controller     string
+	serviceAccount string
+	configMapRefs  []string
+	secretRefs     []string
+	terminated     bool
+	cpuRequests    int64 // In millicores
+	memoryRequests int64 // In bytes
 }
 
 func getPhase(p *corev1.Pod) string {
@@ -56,6 +70,78 @@ func getPhase(p *corev1.Pod) string {
 	return string(p.Status.Phase)
 }
 
+// getPodRequests computes the effective cpu and memory requests of a pod the way the scheduler does:
+// the max between the sum of containers and the highest init container, plus the pod overhead
+func getPodRequests(spec corev1.PodSpec) (int64, int64) {
+	var cpu, memory int64
+	for _, c := range spec.Containers {
+		cpu += c.Resources.Requests.Cpu().MilliValue()
+		memory += c.Resources.Requests.Memory().Value()
+	}
+	for _, c := range spec.InitContainers {
+		if initCPU := c.Resources.Requests.Cpu().MilliValue(); initCPU > cpu {
+			cpu = initCPU
+		}
+		if initMemory := c.Resources.Requests.Memory().Value(); initMemory > memory {
+			memory = initMemory
+		}
+	}
+	cpu += spec.Overhead.Cpu().MilliValue()
+	memory += spec.Overhead.Memory().Value()
+	return cpu, memory
+}
+
+// getPodReferences returns the names of the configmaps and secrets referenced by a pod spec
+func getPodReferences(spec corev1.PodSpec) ([]string, []string) {
+	configMaps := make(map[string]bool)
+	secrets := make(map[string]bool)
+	containers := spec.Containers
+	containers = append(containers, spec.InitContainers...)
+	for _, c := range containers {
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps[env.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets[env.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+		for _, envFrom := range c.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMaps[envFrom.ConfigMapRef.Name] = true
+			}
+			if envFrom.SecretRef != nil {
+				secrets[envFrom.SecretRef.Name] = true
+			}
+		}
+	}
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps[v.ConfigMap.Name] = true
+		}
+		if v.Secret != nil {
+			secrets[v.Secret.SecretName] = true
+		}
+		if v.Projected != nil {
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps[source.ConfigMap.Name] = true
+				}
+				if source.Secret != nil {
+					secrets[source.Secret.Name] = true
+				}
+			}
+		}
+	}
+	for _, s := range spec.ImagePullSecrets {
+		secrets[s.Name] = true
+	}
+	return util.SetToSortedSlice(configMaps), util.SetToSortedSlice(secrets)
+}
+
 // NewPodFromRuntime builds a pod from informer result
 func NewPodFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 	p := &Pod{}
@@ -74,6 +160,8 @@ func (p *Pod) FromRuntime(obj interface{}, config CtorConfig) {
 	p.nodeName = spec.NodeName
 	p.phase = getPhase(pod)
 	p.qosClass = string(pod.Status.QOSClass)
+	p.terminated = pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	p.cpuRequests, p.memoryRequests = getPodRequests(spec)
 
 	fieldSelectors := make([]string, 0)
 	if p.nodeName != "" {
@@ -88,12 +176,41 @@ func (p *Pod) FromRuntime(obj interface{}, config CtorConfig) {
 	for k, v := range containers {
 		p.containers[k] = v.Name
 	}
+	p.images = getImages(containers)
+
+	readyContainers := 0
+	p.terminations = make([]string, 0)
+	for _, v := range pod.Status.ContainerStatuses {
+		if v.Ready {
+			readyContainers++
+		}
+		p.restarts += int(v.RestartCount)
+		if v.LastTerminationState.Terminated != nil && v.LastTerminationState.Terminated.Reason != "" {
+			termination := fmt.Sprintf("%s:%s", v.Name, v.LastTerminationState.Terminated.Reason)
+			p.terminations = append(p.terminations, termination)
+		}
+	}
+	p.ready = fmt.Sprintf("%d/%d", readyContainers, len(spec.Containers))
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			p.podReady = condition.Status == corev1.ConditionTrue
+		}
+	}
 
+	p.serviceAccount = spec.ServiceAccountName
+	p.configMapRefs, p.secretRefs = getPodReferences(spec)
 	volumes := spec.Volumes
 	for _, v := range volumes {
+		claimName := ""
 		if v.PersistentVolumeClaim != nil {
-			fullClaimName := fmt.Sprintf("%s/%s", p.ResourceMeta.namespace,
-				v.PersistentVolumeClaim.ClaimName)
+			claimName = v.PersistentVolumeClaim.ClaimName
+		} else if v.Ephemeral != nil {
+			// Generic ephemeral volumes create a claim named after the pod and the volume
+			claimName = fmt.Sprintf("%s-%s", p.name, v.Name)
+		}
+		if claimName != "" {
+			p.claimNames = append(p.claimNames, claimName)
+			fullClaimName := fmt.Sprintf("%s/%s", p.ResourceMeta.namespace, claimName)
 			p.claims = append(p.claims, fullClaimName)
 		}
 	}
@@ -115,12 +232,65 @@ func (p *Pod) FromRuntime(obj interface{}, config CtorConfig) {
 	}
 }
 
+// IndexValues returns the values used to find the pod from other resources
+func (p *Pod) IndexValues() map[string][]string {
+	return map[string][]string{
+		NodeIndex:           {p.nodeName},
+		NamespaceIndex:      {p.namespace},
+		ConfigMapIndex:      p.namespacedKeys(p.configMapRefs),
+		SecretIndex:         p.namespacedKeys(p.secretRefs),
+		ServiceAccountIndex: p.namespacedKeys([]string{p.serviceAccount}),
+		ClaimIndex:          p.namespacedKeys(p.claimNames),
+	}
+}
+
+// namespacedKeys builds the store keys of resources in the pod's namespace
+func (p *Pod) namespacedKeys(names []string) []string {
+	keys := make([]string, 0, len(names))
+	for _, v := range names {
+		if v != "" {
+			keys = append(keys, ResourceKey(p.namespace, v))
+		}
+	}
+	return keys
+}
+
+// Relations returns the node of the pod and the resources it uses
+func (p *Pod) Relations() []Relation {
+	relations := p.ownerRelations()
+	if p.nodeName != "" {
+		relations = append(relations, Relation{RelationScheduled, "nodes", "", p.nodeName, p.phase})
+	}
+	for _, claim := range p.claimNames {
+		relations = append(relations, Relation{RelationMounts, "persistentvolumeclaims", p.namespace, claim, ""})
+	}
+	for _, configMap := range p.configMapRefs {
+		relations = append(relations, Relation{RelationReferences, "configmaps", p.namespace, configMap, ""})
+	}
+	for _, secret := range p.secretRefs {
+		relations = append(relations, Relation{RelationReferences, "secrets", p.namespace, secret, ""})
+	}
+	if p.serviceAccount != "" {
+		relations = append(relations, Relation{RelationUses, "serviceaccounts", p.namespace, p.serviceAccount, ""})
+	}
+	return relations
+}
+
+// Resolve finds the top-level controller of the pod, e.g. the deployment of its replicaset
+func (p *Pod) Resolve(lookup StoreLookup) {
+	p.controller = p.resolveController(lookup)
+}
+
 // HasChanged returns true if the resource's dump needs to be updated
 func (p *Pod) HasChanged(k K8sResource) bool {
 	oldPod := k.(*Pod)
 	return (p.podIP != oldPod.podIP ||
 		p.phase != oldPod.phase ||
-		util.StringMapsEqual(p.labels, oldPod.labels) ||
+		p.ready != oldPod.ready ||
+		p.restarts != oldPod.restarts ||
+		!util.StringSlicesEqual(p.images, oldPod.images) ||
+		!util.StringSlicesEqual(p.terminations, oldPod.terminations) ||
+		!util.StringMapsEqual(p.labels, oldPod.labels) ||
 		p.nodeName != oldPod.nodeName)
 }
 
@@ -134,10 +304,16 @@ func (p *Pod) ToString() string {
 		p.hostIP,
 		p.nodeName,
 		p.phase,
+		p.ready,
+		strconv.Itoa(p.restarts),
 		p.qosClass,
 		util.TruncateString(util.JoinSlicesOrNone(p.containers, ","), 300),
+		util.TruncateString(util.JoinSlicesOrNone(p.images, ","), 300),
+		util.JoinSlicesOrNone(p.terminations, ","),
 		util.JoinSlicesOrNone(p.tolerations, ","),
 		util.JoinSlicesOrNone(p.claims, ","),
+		p.ownerString(),
+		p.controller,
 		p.resourceAge(),
 		p.labelsString(),
 		p.fieldSelectors,
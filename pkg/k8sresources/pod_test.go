@@ -0,0 +1,92 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{
+			NodeName:   "node1",
+			Containers: []corev1.Container{{Name: "app", Image: "web:1"}},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			PodIP:             "10.0.0.1",
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true}},
+		},
+	}
+}
+
+func TestPodHasChanged(t *testing.T) {
+	var testDatas = []struct {
+		name    string
+		update  func(*corev1.Pod)
+		changed bool
+	}{
+		{"unchanged", func(*corev1.Pod) {}, false},
+		{"labels", func(p *corev1.Pod) { p.Labels["app"] = "api" }, true},
+		{"image", func(p *corev1.Pod) { p.Spec.Containers[0].Image = "web:2" }, true},
+		{"last termination", func(p *corev1.Pod) {
+			p.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: "OOMKilled"}
+		}, true},
+		{"restarts", func(p *corev1.Pod) { p.Status.ContainerStatuses[0].RestartCount = 1 }, true},
+		{"ready", func(p *corev1.Pod) { p.Status.ContainerStatuses[0].Ready = false }, true},
+	}
+	for _, testData := range testDatas {
+		oldPod := NewPodFromRuntime(testPod(), CtorConfig{})
+		pod := testPod()
+		testData.update(pod)
+		newPod := NewPodFromRuntime(pod, CtorConfig{})
+		assert.Equal(t, testData.changed, newPod.HasChanged(oldPod), testData.name)
+	}
+}
+
+func requests(cpu string, memory string) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+}
+
+func containerWithRequests(cpu string, memory string) corev1.Container {
+	return corev1.Container{Resources: corev1.ResourceRequirements{Requests: requests(cpu, memory)}}
+}
+
+func TestGetPodRequests(t *testing.T) {
+	var testDatas = []struct {
+		name           string
+		spec           corev1.PodSpec
+		expectedCPU    int64
+		expectedMemory int64
+	}{
+		{"no requests", corev1.PodSpec{Containers: []corev1.Container{{}}}, 0, 0},
+		{"sum of containers", corev1.PodSpec{
+			Containers: []corev1.Container{containerWithRequests("100m", "64Mi"), containerWithRequests("250m", "128Mi")},
+		}, 350, 192 << 20},
+		{"larger init container", corev1.PodSpec{
+			InitContainers: []corev1.Container{containerWithRequests("1", "1Gi"), containerWithRequests("10m", "1Mi")},
+			Containers:     []corev1.Container{containerWithRequests("100m", "64Mi"), containerWithRequests("250m", "128Mi")},
+		}, 1000, 1 << 30},
+		{"init container larger for cpu only", corev1.PodSpec{
+			InitContainers: []corev1.Container{containerWithRequests("500m", "16Mi")},
+			Containers:     []corev1.Container{containerWithRequests("100m", "64Mi")},
+		}, 500, 64 << 20},
+		{"overhead", corev1.PodSpec{
+			InitContainers: []corev1.Container{containerWithRequests("500m", "16Mi")},
+			Containers:     []corev1.Container{containerWithRequests("100m", "64Mi")},
+			Overhead:       requests("250m", "120Mi"),
+		}, 750, 184 << 20},
+	}
+	for _, testData := range testDatas {
+		cpu, memory := getPodRequests(testData.spec)
+		assert.Equal(t, testData.expectedCPU, cpu, testData.name)
+		assert.Equal(t, testData.expectedMemory, memory, testData.name)
+	}
+}
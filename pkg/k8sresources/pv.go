@@ -8,18 +8,69 @@ import (
 )
 
 // PersistentVolumeHeader is the header for pvc csv
-const PersistentVolumeHeader = "Cluster Name Status StorageClass Zone Claim Volume Affinities Age Labels\n"
+const PersistentVolumeHeader = "Cluster Name Status StorageClass Zone Claim Source Volume AccessModes VolumeMode ReclaimPolicy Capacity Requested Affinities Age Labels\n"
 
 // PersistentVolume is the summary of a kubernetes physical volume
 type PersistentVolume struct {
 	ResourceMeta
-	status       string
-	claim        string
-	volume       string
-	zone         string
-	spec         string
-	affinities   []string
-	storageClass string
+	status         string
+	claim          string
+	claimNamespace string
+	claimName      string
+	source         string
+	volume         string
+	zone           string
+	spec           string
+	affinities     []string
+	affinityNodes  []string
+	storageClass   string
+	accessModes    []string
+	volumeMode     string
+	reclaimPolicy  string
+	capacity       string
+	requested      string
+}
+
+// zoneLabels are the labels holding the zone, by order of preference
+var zoneLabels = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+
+// accessModeShortNames maps access modes to the abbreviations used by kubectl
+var accessModeShortNames = map[corev1.PersistentVolumeAccessMode]string{
+	corev1.ReadWriteOnce:    "RWO",
+	corev1.ReadOnlyMany:     "ROX",
+	corev1.ReadWriteMany:    "RWX",
+	corev1.ReadWriteOncePod: "RWOP",
+}
+
+func getAccessModes(modes []corev1.PersistentVolumeAccessMode) []string {
+	res := make([]string, len(modes))
+	for k, v := range modes {
+		shortName, ok := accessModeShortNames[v]
+		if !ok {
+			shortName = string(v)
+		}
+		res[k] = shortName
+	}
+	return res
+}
+
+// getVolumeSource returns the type of the volume backend and the volume identifier within it
+func getVolumeSource(spec corev1.PersistentVolumeSpec) (string, string) {
+	switch {
+	case spec.CSI != nil:
+		return fmt.Sprintf("CSI:%s", spec.CSI.Driver), spec.CSI.VolumeHandle
+	case spec.AWSElasticBlockStore != nil:
+		return "AWSElasticBlockStore", util.LastURLPart(spec.AWSElasticBlockStore.VolumeID)
+	case spec.GCEPersistentDisk != nil:
+		return "GCEPersistentDisk", spec.GCEPersistentDisk.PDName
+	case spec.NFS != nil:
+		return "NFS", fmt.Sprintf("%s:%s", spec.NFS.Server, spec.NFS.Path)
+	case spec.Local != nil:
+		return "Local", spec.Local.Path
+	case spec.HostPath != nil:
+		return "HostPath", spec.HostPath.Path
+	}
+	return "", ""
 }
 
 // NewPersistentVolumeFromRuntime builds a pod from informer result
@@ -34,33 +85,57 @@ func (pv *PersistentVolume) FromRuntime(obj interface{}, config CtorConfig) {
 	pvFromRuntime := obj.(*corev1.PersistentVolume)
 	pv.FromObjectMeta(pvFromRuntime.ObjectMeta, config)
 	pv.status = string(pvFromRuntime.Status.Phase)
-	var ok bool
-	pv.zone, ok = pv.labels["failure-domain.beta.kubernetes.io/zone"]
-	if !ok {
-		pv.zone = "None"
+	pv.zone = "None"
+	for _, zoneLabel := range zoneLabels {
+		if zone, ok := pv.labels[zoneLabel]; ok {
+			pv.zone = zone
+			break
+		}
 	}
 	spec := pvFromRuntime.Spec
-	if spec.AWSElasticBlockStore != nil {
-		pv.volume = util.LastURLPart(spec.AWSElasticBlockStore.VolumeID)
-	} else if spec.GCEPersistentDisk != nil {
-		pv.volume = spec.GCEPersistentDisk.PDName
-	}
+	pv.source, pv.volume = getVolumeSource(spec)
 	pv.storageClass = spec.StorageClassName
+	pv.accessModes = getAccessModes(spec.AccessModes)
+	pv.volumeMode = ""
+	if spec.VolumeMode != nil {
+		pv.volumeMode = string(*spec.VolumeMode)
+	}
+	pv.reclaimPolicy = string(spec.PersistentVolumeReclaimPolicy)
+	quantity := spec.Capacity[corev1.ResourceStorage]
+	pv.capacity = quantity.String()
 	pv.claim = "None"
 	if pvFromRuntime.Spec.ClaimRef != nil {
 		pv.claim = fmt.Sprintf("%s/%s", spec.ClaimRef.Namespace, spec.ClaimRef.Name)
+		pv.claimNamespace = spec.ClaimRef.Namespace
+		pv.claimName = spec.ClaimRef.Name
 	}
-	if pvFromRuntime.Spec.NodeAffinity != nil {
+	if pvFromRuntime.Spec.NodeAffinity != nil && pvFromRuntime.Spec.NodeAffinity.Required != nil {
 		for _, term := range pvFromRuntime.Spec.NodeAffinity.Required.NodeSelectorTerms {
 			for _, expression := range term.MatchExpressions {
 				affinity := fmt.Sprintf("%s:%s:%s", expression.Key,
 					expression.Operator, util.JoinSlicesOrNone(expression.Values, ";"))
 				pv.affinities = append(pv.affinities, affinity)
+				if expression.Key == corev1.LabelHostname && expression.Operator == corev1.NodeSelectorOpIn {
+					pv.affinityNodes = append(pv.affinityNodes, expression.Values...)
+				}
 			}
 		}
 	}
 }
 
+// Resolve fetches the storage requested by the bound claim
+func (pv *PersistentVolume) Resolve(lookup StoreLookup) {
+	pv.requested = ""
+	if pv.claimName == "" {
+		return
+	}
+	r, ok := lookup.GetResource("persistentvolumeclaims", ResourceKey(pv.claimNamespace, pv.claimName))
+	if !ok {
+		return
+	}
+	pv.requested = r.(*PersistentVolumeClaim).requested
+}
+
 // HasChanged returns true if the resource's dump needs to be updated
 func (pv *PersistentVolume) HasChanged(k K8sResource) bool {
 	return true
@@ -75,7 +150,13 @@ func (pv *PersistentVolume) ToString() string {
 		pv.storageClass,
 		pv.zone,
 		pv.claim,
+		pv.source,
 		pv.volume,
+		util.JoinSlicesOrNone(pv.accessModes, ","),
+		pv.volumeMode,
+		pv.reclaimPolicy,
+		pv.capacity,
+		pv.requested,
 		util.JoinSlicesOrNone(pv.affinities, ","),
 		pv.resourceAge(),
 		pv.labelsString(),
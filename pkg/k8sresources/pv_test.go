@@ -0,0 +1,73 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetVolumeSource(t *testing.T) {
+	var testDatas = []struct {
+		name           string
+		source         corev1.PersistentVolumeSource
+		expectedSource string
+		expectedVolume string
+	}{
+		{"csi", corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+			Driver: "ebs.csi.aws.com", VolumeHandle: "vol-0123456789abcdef0",
+		}}, "CSI:ebs.csi.aws.com", "vol-0123456789abcdef0"},
+		{"aws", corev1.PersistentVolumeSource{AWSElasticBlockStore: &corev1.AWSElasticBlockStoreVolumeSource{
+			VolumeID: "aws://eu-west-1a/vol-0123456789abcdef0",
+		}}, "AWSElasticBlockStore", "vol-0123456789abcdef0"},
+		{"gce", corev1.PersistentVolumeSource{GCEPersistentDisk: &corev1.GCEPersistentDiskVolumeSource{
+			PDName: "disk-1",
+		}}, "GCEPersistentDisk", "disk-1"},
+		{"nfs", corev1.PersistentVolumeSource{NFS: &corev1.NFSVolumeSource{
+			Server: "nfs.example.com", Path: "/exports/data",
+		}}, "NFS", "nfs.example.com:/exports/data"},
+		{"local", corev1.PersistentVolumeSource{Local: &corev1.LocalVolumeSource{
+			Path: "/mnt/disks/ssd1",
+		}}, "Local", "/mnt/disks/ssd1"},
+		{"host path", corev1.PersistentVolumeSource{HostPath: &corev1.HostPathVolumeSource{
+			Path: "/var/data",
+		}}, "HostPath", "/var/data"},
+		{"unknown", corev1.PersistentVolumeSource{}, "", ""},
+	}
+	for _, testData := range testDatas {
+		source, volume := getVolumeSource(corev1.PersistentVolumeSpec{PersistentVolumeSource: testData.source})
+		assert.Equal(t, testData.expectedSource, source, testData.name)
+		assert.Equal(t, testData.expectedVolume, volume, testData.name)
+	}
+}
+
+func TestPersistentVolumeFromRuntime(t *testing.T) {
+	volumeMode := corev1.PersistentVolumeBlock
+	pvObj := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv1", Labels: map[string]string{"failure-domain.beta.kubernetes.io/zone": "eu-west-1a"}},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{Local: &corev1.LocalVolumeSource{Path: "/mnt/disks/ssd1"}},
+			AccessModes:            []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteOncePod, "Custom"},
+			VolumeMode:             &volumeMode,
+			Capacity:               corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			ClaimRef:               &corev1.ObjectReference{Namespace: "ns1", Name: "data"},
+			NodeAffinity: &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: corev1.LabelHostname, Operator: corev1.NodeSelectorOpIn, Values: []string{"node1"}},
+				},
+			}}}},
+		},
+	}
+	pv := NewPersistentVolumeFromRuntime(pvObj, CtorConfig{}).(*PersistentVolume)
+	assert.Equal(t, "Local", pv.source)
+	assert.Equal(t, "/mnt/disks/ssd1", pv.volume)
+	assert.Equal(t, "eu-west-1a", pv.zone)
+	assert.Equal(t, []string{"RWO", "RWOP", "Custom"}, pv.accessModes)
+	assert.Equal(t, "Block", pv.volumeMode)
+	assert.Equal(t, "10Gi", pv.capacity)
+	assert.Equal(t, "ns1/data", pv.claim)
+	assert.Equal(t, []string{"kubernetes.io/hostname:In:node1"}, pv.affinities)
+	assert.Equal(t, []string{"node1"}, pv.affinityNodes)
+}
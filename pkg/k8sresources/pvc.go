@@ -5,15 +5,22 @@ import (
 	"kubectlfzf/pkg/util"
 )
 
-const PersistentVolumeClaimHeader = "Cluster Namespace Name Status Capacity VolumeName StorageClass Age Labels\n"
+const PersistentVolumeClaimHeader = "Cluster Namespace Name Status Requested Capacity AccessModes VolumeMode ReclaimPolicy VolumeName StorageClass NodeAffinity UsedBy Owner Age Labels\n"
 
 // PersistentVolumeClaim is the summary of a kubernetes physical volume claim
 type PersistentVolumeClaim struct {
 	ResourceMeta
-	status       string
-	volumeName   string
-	capacity     string
-	storageClass string
+	status        string
+	volumeName    string
+	requested     string
+	capacity      string
+	storageClass  string
+	accessModes   []string
+	volumeMode    string
+	reclaimPolicy string
+	affinities    []string
+	relations     []Relation
+	usedBy        string
 }
 
 // NewPersistentVolumeClaimFromRuntime builds a pod from informer result
@@ -32,8 +39,48 @@ func (pvc *PersistentVolumeClaim) FromRuntime(obj interface{}, config CtorConfig
 		pvc.storageClass = *pvcFromRuntime.Spec.StorageClassName
 	}
 	pvc.volumeName = pvcFromRuntime.Spec.VolumeName
+	requested := pvcFromRuntime.Spec.Resources.Requests[corev1.ResourceStorage]
+	pvc.requested = requested.String()
 	quantity := pvcFromRuntime.Status.Capacity["storage"]
 	pvc.capacity = quantity.String()
+
+	accessModes := pvcFromRuntime.Status.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = pvcFromRuntime.Spec.AccessModes
+	}
+	pvc.accessModes = getAccessModes(accessModes)
+	pvc.volumeMode = ""
+	if pvcFromRuntime.Spec.VolumeMode != nil {
+		pvc.volumeMode = string(*pvcFromRuntime.Spec.VolumeMode)
+	}
+}
+
+// Resolve finds the pods using the claim and fetches the reclaim policy of the bound volume
+func (pvc *PersistentVolumeClaim) Resolve(lookup StoreLookup) {
+	pvc.usedBy = pvc.resolveUsedBy(lookup, ClaimIndex)
+	pvc.reclaimPolicy = ""
+	pvc.affinities = nil
+	pvc.relations = nil
+	if pvc.volumeName == "" {
+		return
+	}
+	r, ok := lookup.GetResource("persistentvolumes", ResourceKey("", pvc.volumeName))
+	if !ok {
+		pvc.relations = []Relation{{RelationBound, "persistentvolumes", "", pvc.volumeName, "NotFound"}}
+		return
+	}
+	pv := r.(*PersistentVolume)
+	pvc.reclaimPolicy = pv.reclaimPolicy
+	pvc.affinities = pv.affinities
+	pvc.relations = []Relation{{RelationBound, "persistentvolumes", "", pvc.volumeName, pv.status}}
+	for _, node := range pv.affinityNodes {
+		pvc.relations = append(pvc.relations, Relation{RelationAffinity, "nodes", "", node, pv.name})
+	}
+}
+
+// Relations returns the owner of the claim, its bound volume and the nodes the volume is restricted to
+func (pvc *PersistentVolumeClaim) Relations() []Relation {
+	return append(pvc.ownerRelations(), pvc.relations...)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -48,9 +95,16 @@ func (pvc *PersistentVolumeClaim) ToString() string {
 		pvc.namespace,
 		pvc.name,
 		pvc.status,
+		pvc.requested,
 		pvc.capacity,
+		util.JoinSlicesOrNone(pvc.accessModes, ","),
+		pvc.volumeMode,
+		pvc.reclaimPolicy,
 		pvc.volumeName,
 		pvc.storageClass,
+		util.JoinSlicesOrNone(pvc.affinities, ","),
+		pvc.usedBy,
+		pvc.ownerString(),
 		pvc.resourceAge(),
 		pvc.labelsString(),
 	}
@@ -0,0 +1,115 @@
+package k8sresources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"kubectlfzf/pkg/util"
+)
+
+// RelationHeader is the header of the relation files
+const RelationHeader = "Cluster Namespace Name Relation Resource TargetNamespace TargetName Details\n"
+
+// Relation types
+const (
+	RelationSelects    = "selects"
+	RelationBackend    = "backend"
+	RelationScales     = "scales"
+	RelationBound      = "bound"
+	RelationAffinity   = "affinity"
+	RelationOwner      = "owner"
+	RelationScheduled  = "scheduled"
+	RelationMounts     = "mounts"
+	RelationReferences = "references"
+	RelationUses       = "uses"
+)
+
+// Relation is a link from a resource to another resource
+type Relation struct {
+	Type      string
+	Resource  string
+	Namespace string
+	Name      string
+	Details   string
+}
+
+// Relatable is implemented by resources linked to other resources.
+// Relations depending on other stores are computed by Resolve
+type Relatable interface {
+	Relations() []Relation
+}
+
+// ownerRelations returns the relation to the owner of the resource
+func (r *ResourceMeta) ownerRelations() []Relation {
+	if r.ownerKind == "" {
+		return nil
+	}
+	resourceName, ok := ownerResourceNames[r.ownerKind]
+	if !ok {
+		resourceName = strings.ToLower(r.ownerKind) + "s"
+	}
+	return []Relation{{RelationOwner, resourceName, r.namespace, r.ownerName, r.ownerKind}}
+}
+
+// Relations returns the relations shared by all resources
+func (r *ResourceMeta) Relations() []Relation {
+	return r.ownerRelations()
+}
+
+// RelationsToString serializes the relations of a resource, one line per relation
+func RelationsToString(resource K8sResource) string {
+	relatable, ok := resource.(Relatable)
+	if !ok {
+		return ""
+	}
+	meta := resource.(metaAccessor).getMeta()
+	var res strings.Builder
+	for _, relation := range relatable.Relations() {
+		res.WriteString(util.DumpLine([]string{
+			meta.cluster,
+			meta.namespace,
+			meta.name,
+			relation.Type,
+			relation.Resource,
+			relation.Namespace,
+			relation.Name,
+			relation.Details,
+		}))
+	}
+	return res.String()
+}
+
+// selectPods returns the pods of the namespace matching the selector.
+// The boolean is false when pods are not watched
+func selectPods(lookup StoreLookup, namespace string, selector map[string]string) ([]*Pod, bool) {
+	candidates := lookup.GetIndexedResources("pods", NamespaceIndex, namespace)
+	if candidates == nil {
+		return nil, false
+	}
+	pods := make([]*Pod, 0)
+	if len(selector) == 0 {
+		return pods, true
+	}
+	labelSelector := labels.SelectorFromSet(selector)
+	for _, v := range candidates {
+		pod := v.(*Pod)
+		if labelSelector.Matches(labels.Set(pod.labels)) {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].name < pods[j].name })
+	return pods, true
+}
+
+// podsReadyString displays the number of ready pods over the number of pods
+func podsReadyString(pods []*Pod) string {
+	ready := 0
+	for _, pod := range pods {
+		if pod.podReady {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(pods))
+}
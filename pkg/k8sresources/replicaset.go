@@ -9,7 +9,7 @@ import (
 	"kubectlfzf/pkg/util"
 )
 
-const ReplicaSetHeader = "Cluster Namespace Name Replicas AvailableReplicas ReadyReplicas Selector Age Labels\n"
+const ReplicaSetHeader = "Cluster Namespace Name Replicas AvailableReplicas ReadyReplicas Selector Owner Age Labels\n"
 
 // ReplicaSet is the summary of a kubernetes replicaSet
 type ReplicaSet struct {
@@ -59,6 +59,7 @@ func (r *ReplicaSet) ToString() string {
 		r.availableReplicas,
 		r.readyReplicas,
 		selectorList,
+		r.ownerString(),
 		r.resourceAge(),
 		r.labelsString(),
 	}, " ")
@@ -7,15 +7,19 @@ import (
 
 	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-const SecretHeader = "Cluster Namespace Name Type Data Age Labels\n"
+const SecretHeader = "Cluster Namespace Name Type Data Keys UsedBy Owner Age Labels\n"
 
 // Secret is the summary of a kubernetes secret
 type Secret struct {
 	ResourceMeta
-	secretType string
-	data       string
+	secretType   string
+	data         string
+	keys         []string
+	metadataOnly bool // Set when watched with metadata only, without data
+	usedBy       string
 }
 
 // NewSecretFromRuntime builds a secret from informer result
@@ -27,11 +31,35 @@ func NewSecretFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 
 // FromRuntime builds object from the informer's result
 func (s *Secret) FromRuntime(obj interface{}, config CtorConfig) {
+	if partial, ok := obj.(*metav1.PartialObjectMetadata); ok {
+		// Watched with metadata only, type and data are not available
+		s.FromObjectMeta(partial.ObjectMeta, config)
+		s.metadataOnly = true
+		s.secretType = "Unknown"
+		s.data = "Unknown"
+		return
+	}
 	secret := obj.(*corev1.Secret)
 	glog.V(19).Infof("Reading meta %#v", secret)
 	s.FromObjectMeta(secret.ObjectMeta, config)
 	s.secretType = string(secret.Type)
 	s.data = strconv.Itoa(len(secret.Data))
+	if !config.HideSecretKeys {
+		dataKeys := make([]string, 0, len(secret.Data))
+		for k := range secret.Data {
+			dataKeys = append(dataKeys, k)
+		}
+		stringDataKeys := make([]string, 0, len(secret.StringData))
+		for k := range secret.StringData {
+			stringDataKeys = append(stringDataKeys, k)
+		}
+		s.keys = getSortedKeys(dataKeys, stringDataKeys)
+	}
+}
+
+// Resolve finds the pods using the secret
+func (s *Secret) Resolve(lookup StoreLookup) {
+	s.usedBy = s.resolveUsedBy(lookup, SecretIndex)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -47,6 +75,9 @@ func (s *Secret) ToString() string {
 		s.name,
 		s.secretType,
 		s.data,
+		keysString(s.keys, s.metadataOnly),
+		s.usedBy,
+		s.ownerString(),
 		s.resourceAge(),
 		s.labelsString(),
 	}, " ")
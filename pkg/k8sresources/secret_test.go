@@ -0,0 +1,35 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecretKeys(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns1"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("hunter2"), "user": []byte("admin")},
+		StringData: map[string]string{"host": "db.example.com", "user": "admin"},
+	}
+	var testDatas = []struct {
+		name         string
+		obj          interface{}
+		config       CtorConfig
+		expectedData string
+		expectedKeys string
+	}{
+		{"keys", secret, CtorConfig{}, "2", "host,password,user"},
+		{"hidden keys", secret, CtorConfig{HideSecretKeys: true}, "2", "None"},
+		{"metadata only", &metav1.PartialObjectMetadata{ObjectMeta: secret.ObjectMeta}, CtorConfig{}, "Unknown", "Unknown"},
+	}
+	for _, testData := range testDatas {
+		s := NewSecretFromRuntime(testData.obj, testData.config).(*Secret)
+		assert.Equal(t, testData.expectedData, s.data, testData.name)
+		assert.Equal(t, testData.expectedKeys, keysString(s.keys, s.metadataOnly), testData.name)
+		assert.NotContains(t, s.ToString(), "hunter2", testData.name)
+	}
+}
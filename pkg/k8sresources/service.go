@@ -2,21 +2,27 @@ package k8sresources
 
 import (
 	"fmt"
-	"strings"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
 	"kubectlfzf/pkg/util"
 )
 
-const ServiceHeader = "Cluster Namespace Name Type ClusterIp Ports Selector Age Labels\n"
+const ServiceHeader = "Cluster Namespace Name Type ClusterIp ExternalAddress Ports TargetPorts Selector ReadyEndpoints Pods Owner Age Labels\n"
 
 // Service is the summary of a kubernetes service
 type Service struct {
 	ResourceMeta
-	serviceType string
-	clusterIP   string
-	ports       []string
-	selectors   []string
+	serviceType       string
+	clusterIP         string
+	externalAddresses []string
+	ports             []string
+	targetPorts       []string
+	selectors         []string
+	selector          map[string]string
+	readyEndpoints    string
+	pods              string
+	relations         []Relation
 }
 
 // NewServiceFromRuntime builds a pod from informer result
@@ -41,30 +47,110 @@ func (s *Service) FromRuntime(obj interface{}, config CtorConfig) {
 		}
 	}
 	s.selectors = util.JoinStringMap(service.Spec.Selector, ExcludedLabels, "=")
+	s.selector = service.Spec.Selector
+
+	s.targetPorts = make([]string, len(service.Spec.Ports))
+	for k, v := range service.Spec.Ports {
+		targetPort := v.TargetPort.String()
+		if targetPort == "0" {
+			// Defaults to the service port when not set
+			targetPort = strconv.Itoa(int(v.Port))
+		}
+		s.targetPorts[k] = fmt.Sprintf("%d->%s/%s", v.Port, targetPort, v.Protocol)
+	}
+
+	// Same sources as kubectl's External-IP column
+	s.externalAddresses = make([]string, 0)
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		s.externalAddresses = append(s.externalAddresses, service.Spec.ExternalName)
+	}
+	for _, lb := range service.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			s.externalAddresses = append(s.externalAddresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			s.externalAddresses = append(s.externalAddresses, lb.Hostname)
+		}
+	}
+	s.externalAddresses = append(s.externalAddresses, service.Spec.ExternalIPs...)
+}
+
+// Resolve counts the ready addresses of the service's endpoints and the ready pods matching its selector
+func (s *Service) Resolve(lookup StoreLookup) {
+	s.resolvePods(lookup)
+	s.readyEndpoints = ""
+	r, ok := lookup.GetResource("endpoints", ResourceKey(s.namespace, s.name))
+	if !ok {
+		return
+	}
+	endpoints := r.(*Endpoints)
+	total := len(endpoints.readyIps) + len(endpoints.notReadyIps)
+	s.readyEndpoints = fmt.Sprintf("%d/%d", len(endpoints.readyIps), total)
+}
+
+func (s *Service) resolvePods(lookup StoreLookup) {
+	s.relations = nil
+	if len(s.selector) == 0 {
+		// Endpoints of services without selector are managed manually
+		s.pods = "None"
+		return
+	}
+	pods, ok := selectPods(lookup, s.namespace, s.selector)
+	if !ok {
+		s.pods = "Unknown"
+		return
+	}
+	s.pods = podsReadyString(pods)
+	s.relations = make([]Relation, len(pods))
+	for k, pod := range pods {
+		status := "NotReady"
+		if pod.podReady {
+			status = "Ready"
+		}
+		s.relations[k] = Relation{RelationSelects, "pods", pod.namespace, pod.name, status}
+	}
+}
+
+// Relations returns the owner of the service and the pods it selects
+func (s *Service) Relations() []Relation {
+	return append(s.ownerRelations(), s.relations...)
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
 func (s *Service) HasChanged(k K8sResource) bool {
 	oldService := k.(*Service)
-	return (util.StringSlicesEqual(s.ports, oldService.ports) ||
-		util.StringSlicesEqual(s.selectors, oldService.selectors) ||
-		util.StringMapsEqual(s.labels, oldService.labels))
+	return (s.serviceType != oldService.serviceType ||
+		s.clusterIP != oldService.clusterIP ||
+		!util.StringSlicesEqual(s.externalAddresses, oldService.externalAddresses) ||
+		!util.StringSlicesEqual(s.ports, oldService.ports) ||
+		!util.StringSlicesEqual(s.targetPorts, oldService.targetPorts) ||
+		!util.StringSlicesEqual(s.selectors, oldService.selectors) ||
+		s.readyEndpoints != oldService.readyEndpoints ||
+		s.pods != oldService.pods ||
+		s.ownerKind != oldService.ownerKind ||
+		s.ownerName != oldService.ownerName ||
+		!util.StringMapsEqual(s.labels, oldService.labels))
 }
 
 // ToString serializes the object to strings
 func (s *Service) ToString() string {
 	portList := util.JoinSlicesOrNone(s.ports, ",")
 	selectorList := util.JoinSlicesOrNone(s.selectors, ",")
-	line := strings.Join([]string{
+	lst := []string{
 		s.cluster,
 		s.namespace,
 		s.name,
 		s.serviceType,
 		s.clusterIP,
+		util.JoinSlicesOrNone(s.externalAddresses, ","),
 		portList,
+		util.JoinSlicesOrNone(s.targetPorts, ","),
 		selectorList,
+		s.readyEndpoints,
+		s.pods,
+		s.ownerString(),
 		s.resourceAge(),
 		s.labelsString(),
-	}, " ")
-	return fmt.Sprintf("%s\n", line)
+	}
+	return util.DumpLine(lst)
 }
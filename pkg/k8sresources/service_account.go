@@ -8,12 +8,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-const ServiceAccountHeader = "Cluster Namespace Name Secrets Age Labels\n"
+const ServiceAccountHeader = "Cluster Namespace Name Secrets UsedBy Owner Age Labels\n"
 
 // ServiceAccount is the summary of a kubernetes service account
 type ServiceAccount struct {
 	ResourceMeta
 	numberSecrets string
+	usedBy        string
 }
 
 // NewServiceAccountFromRuntime builds a pod from informer result
@@ -30,6 +31,11 @@ func (s *ServiceAccount) FromRuntime(obj interface{}, config CtorConfig) {
 	s.numberSecrets = strconv.Itoa(len(serviceAccount.Secrets))
 }
 
+// Resolve finds the pods using the service account
+func (s *ServiceAccount) Resolve(lookup StoreLookup) {
+	s.usedBy = s.resolveUsedBy(lookup, ServiceAccountIndex)
+}
+
 // HasChanged returns true if the resource's dump needs to be updated
 func (s *ServiceAccount) HasChanged(k K8sResource) bool {
 	return true
@@ -42,6 +48,8 @@ func (s *ServiceAccount) ToString() string {
 		s.namespace,
 		s.name,
 		s.numberSecrets,
+		s.usedBy,
+		s.ownerString(),
 		s.resourceAge(),
 		s.labelsString(),
 	}, " ")
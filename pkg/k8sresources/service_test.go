@@ -0,0 +1,63 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns1", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: "10.0.0.1",
+			Ports:     []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP}},
+			Selector:  map[string]string{"app": "web"},
+		},
+	}
+}
+
+// resolvedService builds a service with the fields filled by Resolve
+func resolvedService(service *corev1.Service) *Service {
+	s := NewServiceFromRuntime(service, CtorConfig{}).(*Service)
+	s.readyEndpoints = "1/1"
+	s.pods = "1/1"
+	return s
+}
+
+func TestServiceHasChanged(t *testing.T) {
+	var testDatas = []struct {
+		name    string
+		update  func(*corev1.Service)
+		resolve func(*Service)
+		changed bool
+	}{
+		{"unchanged", func(*corev1.Service) {}, nil, false},
+		{"type", func(s *corev1.Service) { s.Spec.Type = corev1.ServiceTypeNodePort }, nil, true},
+		{"cluster ip", func(s *corev1.Service) { s.Spec.ClusterIP = "10.0.0.2" }, nil, true},
+		{"external ip", func(s *corev1.Service) { s.Spec.ExternalIPs = []string{"1.2.3.4"} }, nil, true},
+		{"port", func(s *corev1.Service) { s.Spec.Ports[0].Port = 81 }, nil, true},
+		{"target port", func(s *corev1.Service) { s.Spec.Ports[0].TargetPort = intstr.FromInt(8081) }, nil, true},
+		{"selector", func(s *corev1.Service) { s.Spec.Selector = map[string]string{"app": "api"} }, nil, true},
+		{"labels", func(s *corev1.Service) { s.Labels = map[string]string{"app": "api"} }, nil, true},
+		{"owner", func(s *corev1.Service) {
+			s.OwnerReferences = []metav1.OwnerReference{{Kind: "Application", Name: "web"}}
+		}, nil, true},
+		{"ready endpoints", func(*corev1.Service) {}, func(s *Service) { s.readyEndpoints = "0/1" }, true},
+		{"pods", func(*corev1.Service) {}, func(s *Service) { s.pods = "0/1" }, true},
+	}
+	for _, testData := range testDatas {
+		oldService := resolvedService(testService())
+		service := testService()
+		testData.update(service)
+		newService := resolvedService(service)
+		if testData.resolve != nil {
+			testData.resolve(newService)
+		}
+		assert.Equal(t, testData.changed, newService.HasChanged(oldService), testData.name)
+	}
+}
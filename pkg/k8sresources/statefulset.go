@@ -2,20 +2,25 @@ package k8sresources
 
 import (
 	"fmt"
-	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	"kubectlfzf/pkg/util"
 )
 
-const StatefulSetHeader = "Cluster Namespace Name Replicas Selector Age Labels\n"
+const StatefulSetHeader = "Cluster Namespace Name Replicas Ready Selector Generation Strategy CurrentRevision UpdateRevision Images Owner Age Labels\n"
 
 // StatefulSet is the summary of a kubernetes statefulset
 type StatefulSet struct {
 	ResourceMeta
 	currentReplicas int
 	replicas        int
+	readyReplicas   int
 	selectors       []string
+	generation      string
+	strategy        string
+	currentRevision string
+	updateRevision  string
+	images          []string
 }
 
 // NewStatefulSetFromRuntime builds a k8sresource from informer result
@@ -31,7 +36,13 @@ func (s *StatefulSet) FromRuntime(obj interface{}, config CtorConfig) {
 	s.FromObjectMeta(statefulset.ObjectMeta, config)
 	s.currentReplicas = int(statefulset.Status.CurrentReplicas)
 	s.replicas = int(statefulset.Status.Replicas)
+	s.readyReplicas = int(statefulset.Status.ReadyReplicas)
 	s.selectors = util.JoinStringMap(statefulset.Spec.Selector.MatchLabels, ExcludedLabels, "=")
+	s.generation = generationStatus(statefulset.Generation, statefulset.Status.ObservedGeneration)
+	s.strategy = string(statefulset.Spec.UpdateStrategy.Type)
+	s.currentRevision = statefulset.Status.CurrentRevision
+	s.updateRevision = statefulset.Status.UpdateRevision
+	s.images = getImages(append(statefulset.Spec.Template.Spec.Containers, statefulset.Spec.Template.Spec.InitContainers...))
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -39,21 +50,36 @@ func (s *StatefulSet) HasChanged(k K8sResource) bool {
 	oldSts := k.(*StatefulSet)
 	return (s.currentReplicas != oldSts.currentReplicas ||
 		s.replicas != oldSts.replicas ||
-		util.StringSlicesEqual(s.selectors, oldSts.selectors) ||
-		util.StringMapsEqual(s.labels, oldSts.labels))
+		s.readyReplicas != oldSts.readyReplicas ||
+		s.generation != oldSts.generation ||
+		s.currentRevision != oldSts.currentRevision ||
+		s.updateRevision != oldSts.updateRevision ||
+		s.strategy != oldSts.strategy ||
+		!util.StringSlicesEqual(s.selectors, oldSts.selectors) ||
+		!util.StringSlicesEqual(s.images, oldSts.images) ||
+		s.ownerKind != oldSts.ownerKind ||
+		s.ownerName != oldSts.ownerName ||
+		!util.StringMapsEqual(s.labels, oldSts.labels))
 }
 
 // ToString serializes the object to strings
 func (s *StatefulSet) ToString() string {
 	selectorList := util.JoinSlicesOrNone(s.selectors, ",")
-	line := strings.Join([]string{
+	lst := []string{
 		s.cluster,
 		s.namespace,
 		s.name,
 		fmt.Sprintf("%d/%d", s.currentReplicas, s.replicas),
+		fmt.Sprintf("%d/%d", s.readyReplicas, s.replicas),
 		selectorList,
+		s.generation,
+		s.strategy,
+		s.currentRevision,
+		s.updateRevision,
+		util.JoinSlicesOrNone(s.images, ","),
+		s.ownerString(),
 		s.resourceAge(),
 		s.labelsString(),
-	}, " ")
-	return fmt.Sprintf("%s\n", line)
+	}
+	return util.DumpLine(lst)
 }
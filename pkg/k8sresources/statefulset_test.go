@@ -0,0 +1,51 @@
+package k8sresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testStatefulSet() *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns1", Generation: 2, Labels: map[string]string{"app": "db"}},
+		Spec: appsv1.StatefulSetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "db", Image: "db:2"}},
+			}},
+		},
+		Status: appsv1.StatefulSetStatus{
+			Replicas: 3, CurrentReplicas: 3, ReadyReplicas: 3, ObservedGeneration: 2,
+			CurrentRevision: "db-1", UpdateRevision: "db-1",
+		},
+	}
+}
+
+func TestStatefulSetHasChanged(t *testing.T) {
+	var testDatas = []struct {
+		name    string
+		update  func(*appsv1.StatefulSet)
+		changed bool
+	}{
+		{"unchanged", func(*appsv1.StatefulSet) {}, false},
+		{"ready", func(s *appsv1.StatefulSet) { s.Status.ReadyReplicas = 2 }, true},
+		{"generation", func(s *appsv1.StatefulSet) { s.Generation = 3 }, true},
+		{"strategy", func(s *appsv1.StatefulSet) { s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType }, true},
+		{"update revision", func(s *appsv1.StatefulSet) { s.Status.UpdateRevision = "db-2" }, true},
+		{"selector", func(s *appsv1.StatefulSet) { s.Spec.Selector.MatchLabels = map[string]string{"app": "db2"} }, true},
+		{"image", func(s *appsv1.StatefulSet) { s.Spec.Template.Spec.Containers[0].Image = "db:3" }, true},
+		{"labels", func(s *appsv1.StatefulSet) { s.Labels = map[string]string{"app": "db", "team": "data"} }, true},
+	}
+	for _, testData := range testDatas {
+		oldSts := NewStatefulSetFromRuntime(testStatefulSet(), CtorConfig{})
+		sts := testStatefulSet()
+		testData.update(sts)
+		newSts := NewStatefulSetFromRuntime(sts, CtorConfig{})
+		assert.Equal(t, testData.changed, newSts.HasChanged(oldSts), testData.name)
+	}
+}
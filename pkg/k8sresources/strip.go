@@ -0,0 +1,114 @@
+package k8sresources
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchbetav1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+)
+
+// stripContainers removes the container fields which are not used by the summaries.
+// Env entries are kept only when they reference a configmap or a secret
+func stripContainers(containers []corev1.Container) {
+	for i := range containers {
+		c := &containers[i]
+		env := make([]corev1.EnvVar, 0)
+		for _, v := range c.Env {
+			if v.ValueFrom != nil && (v.ValueFrom.ConfigMapKeyRef != nil || v.ValueFrom.SecretKeyRef != nil) {
+				env = append(env, corev1.EnvVar{Name: v.Name, ValueFrom: v.ValueFrom})
+			}
+		}
+		c.Env = env
+		c.Command = nil
+		c.Args = nil
+		c.WorkingDir = ""
+		c.Ports = nil
+		c.VolumeMounts = nil
+		c.VolumeDevices = nil
+		c.LivenessProbe = nil
+		c.ReadinessProbe = nil
+		c.StartupProbe = nil
+		c.Lifecycle = nil
+		c.SecurityContext = nil
+	}
+}
+
+// stripPodSpec removes the pod spec fields which are not used by the summaries
+func stripPodSpec(spec *corev1.PodSpec) {
+	stripContainers(spec.Containers)
+	stripContainers(spec.InitContainers)
+	spec.EphemeralContainers = nil
+	spec.Affinity = nil
+	spec.SecurityContext = nil
+	spec.TopologySpreadConstraints = nil
+	spec.HostAliases = nil
+	spec.DNSConfig = nil
+}
+
+// keptAnnotations are the annotations used by the summaries
+var keptAnnotations = []string{appsv1.DeprecatedTemplateGeneration}
+
+// stripAnnotations returns the annotations used by the summaries, nil if there's none
+func stripAnnotations(annotations map[string]string) map[string]string {
+	var kept map[string]string
+	for _, k := range keptAnnotations {
+		if v, ok := annotations[k]; ok {
+			if kept == nil {
+				kept = make(map[string]string)
+			}
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// StripObject removes the fields of an informer object which are not used by its summary.
+// It's used as an informer transform so the full objects are never kept in the informer cache
+func StripObject(obj interface{}) (interface{}, error) {
+	if accessor, err := apimeta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+		accessor.SetAnnotations(stripAnnotations(accessor.GetAnnotations()))
+	}
+	switch v := obj.(type) {
+	case *corev1.Pod:
+		stripPodSpec(&v.Spec)
+		for i := range v.Status.Conditions {
+			v.Status.Conditions[i].Message = ""
+		}
+	case *corev1.ConfigMap:
+		// Only the keys are displayed
+		for k := range v.Data {
+			v.Data[k] = ""
+		}
+		for k := range v.BinaryData {
+			v.BinaryData[k] = nil
+		}
+	case *corev1.Secret:
+		// Only the keys are displayed, only the metadata of helm releases is kept
+		for k, data := range v.Data {
+			if v.Type == HelmReleaseSecretType && k == "release" {
+				v.Data[k] = stripHelmRelease(data)
+			} else {
+				v.Data[k] = nil
+			}
+		}
+		v.StringData = nil
+	case *appsv1.Deployment:
+		stripPodSpec(&v.Spec.Template.Spec)
+	case *appsv1.StatefulSet:
+		stripPodSpec(&v.Spec.Template.Spec)
+		v.Spec.VolumeClaimTemplates = nil
+	case *appsv1.DaemonSet:
+		stripPodSpec(&v.Spec.Template.Spec)
+	case *appsv1.ReplicaSet:
+		stripPodSpec(&v.Spec.Template.Spec)
+	case *batchv1.Job:
+		stripPodSpec(&v.Spec.Template.Spec)
+	case *batchv1.CronJob:
+		stripPodSpec(&v.Spec.JobTemplate.Spec.Template.Spec)
+	case *batchbetav1.CronJob:
+		stripPodSpec(&v.Spec.JobTemplate.Spec.Template.Spec)
+	}
+	return obj, nil
+}
@@ -0,0 +1,152 @@
+package k8sresources
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func benchmarkObjectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels:    map[string]string{"app": "web", "team": "platform"},
+		Annotations: map[string]string{
+			"kubectl.kubernetes.io/last-applied-configuration": strings.Repeat("x", 2048),
+		},
+		ManagedFields: []metav1.ManagedFieldsEntry{{
+			Manager:    "kube-controller-manager",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fmt.Sprintf(`{"f:metadata":{"f:labels":{%q:{}}}}`, strings.Repeat("y", 1024)))},
+		}},
+	}
+}
+
+func benchmarkContainer(name string) corev1.Container {
+	env := make([]corev1.EnvVar, 20)
+	for i := range env {
+		env[i] = corev1.EnvVar{Name: fmt.Sprintf("VAR_%d", i), Value: strings.Repeat("v", 64)}
+	}
+	env = append(env, corev1.EnvVar{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "password"},
+	}})
+	probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromInt(8080)}}}
+	return corev1.Container{
+		Name:    name,
+		Image:   "registry.example.com/web:1.2.3",
+		Command: []string{"/bin/web", "--config", "/etc/web/config.yaml"},
+		Args:    []string{strings.Repeat("a", 256)},
+		Env:     env,
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi"),
+		}},
+		VolumeMounts:   []corev1.VolumeMount{{Name: "config", MountPath: "/etc/web"}},
+		LivenessProbe:  probe,
+		ReadinessProbe: probe,
+		Ports:          []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+	}
+}
+
+// benchmarkPayloads returns json payloads like the ones decoded from the apiserver
+func benchmarkPayloads(b *testing.B) (pod []byte, configMap []byte) {
+	p := corev1.Pod{
+		ObjectMeta: benchmarkObjectMeta("web"),
+		Spec: corev1.PodSpec{
+			Containers:     []corev1.Container{benchmarkContainer("web"), benchmarkContainer("sidecar")},
+			InitContainers: []corev1.Container{benchmarkContainer("init")},
+			NodeName:       "node1",
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, Conditions: []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue, Message: strings.Repeat("m", 128)},
+		}},
+	}
+	data := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		data[fmt.Sprintf("file-%d.yaml", i)] = strings.Repeat("d", 1024)
+	}
+	c := corev1.ConfigMap{ObjectMeta: benchmarkObjectMeta("config"), Data: data}
+	pod, err := json.Marshal(p)
+	assert.Nil(b, err)
+	configMap, err = json.Marshal(c)
+	assert.Nil(b, err)
+	return pod, configMap
+}
+
+// benchmarkCacheMemory measures the heap retained by objects kept in an informer cache
+func benchmarkCacheMemory(b *testing.B, newObject func() interface{}, transform func(interface{}) (interface{}, error)) {
+	const objects = 1000
+	var total int64
+	for n := 0; n < b.N; n++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		cache := make([]interface{}, objects)
+		for i := range cache {
+			obj := newObject()
+			if transform != nil {
+				obj, _ = transform(obj)
+			}
+			cache[i] = obj
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		total += int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(cache)
+	}
+	b.ReportMetric(float64(total)/float64(b.N*objects), "heap-bytes/object")
+}
+
+func BenchmarkInformerCacheMemory(b *testing.B) {
+	podPayload, configMapPayload := benchmarkPayloads(b)
+	newPod := func() interface{} {
+		pod := &corev1.Pod{}
+		json.Unmarshal(podPayload, pod)
+		return pod
+	}
+	newConfigMap := func() interface{} {
+		configMap := &corev1.ConfigMap{}
+		json.Unmarshal(configMapPayload, configMap)
+		return configMap
+	}
+	newPartialConfigMap := func() interface{} {
+		// The apiserver only sends the metadata of PartialObjectMetadata
+		partial := &metav1.PartialObjectMetadata{}
+		json.Unmarshal(configMapPayload, partial)
+		return partial
+	}
+
+	b.Run("pods/full", func(b *testing.B) { benchmarkCacheMemory(b, newPod, nil) })
+	b.Run("pods/stripped", func(b *testing.B) { benchmarkCacheMemory(b, newPod, StripObject) })
+	b.Run("configmaps/full", func(b *testing.B) { benchmarkCacheMemory(b, newConfigMap, nil) })
+	b.Run("configmaps/stripped", func(b *testing.B) { benchmarkCacheMemory(b, newConfigMap, StripObject) })
+	b.Run("configmaps/metadata-only", func(b *testing.B) { benchmarkCacheMemory(b, newPartialConfigMap, StripObject) })
+}
+
+func TestStripObjectKeepsSummary(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: benchmarkObjectMeta("web"),
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{benchmarkContainer("web")}, NodeName: "node1"},
+	}
+	expected := NewPodFromRuntime(pod.DeepCopy(), CtorConfig{}).ToString()
+	stripped, err := StripObject(pod)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, NewPodFromRuntime(stripped, CtorConfig{}).ToString())
+	assert.Len(t, pod.Spec.Containers[0].Env, 1)
+	assert.Nil(t, pod.ManagedFields)
+
+	configMap := &corev1.ConfigMap{ObjectMeta: benchmarkObjectMeta("config"), Data: map[string]string{"a": "b"}}
+	expected = NewConfigMapFromRuntime(configMap.DeepCopy(), CtorConfig{}).ToString()
+	stripped, err = StripObject(configMap)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, NewConfigMapFromRuntime(stripped, CtorConfig{}).ToString())
+}
@@ -0,0 +1,241 @@
+package resourcewatcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"kubectlfzf/pkg/util"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedauthorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// rulesReviewWorkers is the number of namespaces whose rules are reviewed concurrently
+const rulesReviewWorkers = 10
+
+// Access modes of a resource, decided from the RBAC permissions at startup
+const (
+	AccessCluster    = "cluster"    // Listed and watched cluster-wide
+	AccessNamespaces = "namespaces" // Listed and watched in the accessible namespaces only
+	AccessForbidden  = "forbidden"  // Not accessible, the resource is skipped
+)
+
+// resourceAccess is the way a resource can be watched with the current permissions
+type resourceAccess struct {
+	mode       string
+	namespaces []string // Accessible namespaces with AccessNamespaces
+}
+
+// String serializes the access for the <resource>_access file read by the shell
+func (a resourceAccess) String() string {
+	if a.mode == AccessNamespaces {
+		return fmt.Sprintf("%s %s", a.mode, strings.Join(a.namespaces, ","))
+	}
+	return a.mode
+}
+
+// resourceGroup returns the api group of the objects of a watch config
+func resourceGroup(cfg WatchConfig) string {
+	gvks, _, err := scheme.Scheme.ObjectKinds(cfg.runtimeObject)
+	if err != nil || len(gvks) == 0 {
+		return ""
+	}
+	return gvks[0].Group
+}
+
+// accessCache keeps the permissions reviewed by the watcher so restarted resources don't review them again
+type accessCache struct {
+	clusterWide map[string]bool                                      // Cluster-wide list and watch access by group/resource
+	rules       map[string]*authorizationv1.SubjectRulesReviewStatus // Rules of each reviewed namespace
+}
+
+func newAccessCache() *accessCache {
+	return &accessCache{clusterWide: make(map[string]bool), rules: make(map[string]*authorizationv1.SubjectRulesReviewStatus)}
+}
+
+// canListWatch checks with SelfSubjectAccessReviews that the resource can be listed and watched in the namespace
+func canListWatch(ctx context.Context, accessReviews typedauthorizationv1.SelfSubjectAccessReviewInterface,
+	group string, resourceName string, namespace string) (bool, error) {
+	for _, verb := range []string{"list", "watch"} {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      verb,
+					Group:     group,
+					Resource:  resourceName,
+				},
+			},
+		}
+		res, err := accessReviews.Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "Error reviewing %s access to %s", verb, resourceName)
+		}
+		if !res.Status.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ruleAllows returns true if a rule grants the verb on every object of the resource
+func ruleAllows(rule authorizationv1.ResourceRule, verb string, group string, resourceName string) bool {
+	if len(rule.ResourceNames) > 0 {
+		return false
+	}
+	return ruleMatches(rule.Verbs, verb) && ruleMatches(rule.APIGroups, group) && ruleMatches(rule.Resources, resourceName)
+}
+
+// ruleMatches returns true if the values of a rule contain the value or the wildcard
+func ruleMatches(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// rulesAllowListWatch returns true if the rules of a namespace allow to list and watch the resource
+func rulesAllowListWatch(rules []authorizationv1.ResourceRule, group string, resourceName string) bool {
+	for _, verb := range []string{"list", "watch"} {
+		allowed := false
+		for _, rule := range rules {
+			if ruleAllows(rule, verb, group, resourceName) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// reviewNamespaceRules fetches with SelfSubjectRulesReviews the rules of the namespaces not reviewed yet.
+// Namespaces are reviewed concurrently, the ones failing are reviewed again on the next check
+func (r *ResourceWatcher) reviewNamespaceRules(ctx context.Context, namespaces []string) {
+	type namespaceRules struct {
+		namespace string
+		status    *authorizationv1.SubjectRulesReviewStatus
+	}
+	pending := make(chan string, len(namespaces))
+	for _, namespace := range namespaces {
+		if _, ok := r.accessCache.rules[namespace]; !ok {
+			pending <- namespace
+		}
+	}
+	close(pending)
+	results := make(chan namespaceRules, len(namespaces))
+	var wg sync.WaitGroup
+	for i := 0; i < rulesReviewWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for namespace := range pending {
+				review := &authorizationv1.SelfSubjectRulesReview{
+					Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+				}
+				res, err := r.authorization.SelfSubjectRulesReviews().Create(ctx, review, metav1.CreateOptions{})
+				if err != nil {
+					glog.Warningf("Error reviewing rules of namespace %s: %v", namespace, err)
+					continue
+				}
+				results <- namespaceRules{namespace, &res.Status}
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	for result := range results {
+		r.accessCache.rules[result.namespace] = result.status
+	}
+}
+
+// canListWatchInNamespace checks the reviewed rules of a namespace. Incomplete rules, e.g. with
+// a webhook authorizer, fall back to access reviews
+func (r *ResourceWatcher) canListWatchInNamespace(ctx context.Context, group string, resourceName string, namespace string) (bool, error) {
+	status, ok := r.accessCache.rules[namespace]
+	if !ok {
+		return false, fmt.Errorf("Rules of namespace %s couldn't be reviewed", namespace)
+	}
+	if rulesAllowListWatch(status.ResourceRules, group, resourceName) {
+		return true, nil
+	}
+	if status.Incomplete {
+		return canListWatch(ctx, r.authorization.SelfSubjectAccessReviews(), group, resourceName, namespace)
+	}
+	return false, nil
+}
+
+// checkAccess decides how a resource can be watched. Resources which can't be listed cluster-wide
+// are watched in the accessible namespaces, and skipped if there are none.
+// Reviewed permissions are cached for the life of the watcher
+func (r *ResourceWatcher) checkAccess(ctx context.Context, cfg WatchConfig) resourceAccess {
+	if r.authorization == nil {
+		return resourceAccess{mode: AccessCluster}
+	}
+	group := resourceGroup(cfg)
+	key := fmt.Sprintf("%s/%s", group, cfg.resourceName)
+	allowed, ok := r.accessCache.clusterWide[key]
+	if !ok {
+		var err error
+		allowed, err = canListWatch(ctx, r.authorization.SelfSubjectAccessReviews(), group, cfg.resourceName, "")
+		if err != nil {
+			glog.Warningf("Assuming %s can be watched: %v", cfg.resourceName, err)
+			return resourceAccess{mode: AccessCluster}
+		}
+		r.accessCache.clusterWide[key] = allowed
+	}
+	if allowed {
+		return resourceAccess{mode: AccessCluster}
+	}
+	if !cfg.hasNamespace || cfg.pollingPeriod > 0 {
+		return resourceAccess{mode: AccessForbidden}
+	}
+
+	candidates := r.candidateNamespaces()
+	r.reviewNamespaceRules(ctx, candidates)
+	namespaces := make([]string, 0)
+	for _, namespace := range candidates {
+		allowed, err := r.canListWatchInNamespace(ctx, group, cfg.resourceName, namespace)
+		if err != nil {
+			glog.Warningf("Skipping namespace %s for %s: %v", namespace, cfg.resourceName, err)
+			continue
+		}
+		if allowed {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		return resourceAccess{mode: AccessForbidden}
+	}
+	return resourceAccess{mode: AccessNamespaces, namespaces: namespaces}
+}
+
+// candidateNamespaces returns the namespaces to check when a resource can't be listed cluster-wide
+func (r *ResourceWatcher) candidateNamespaces() []string {
+	if r.namespacesListed {
+		return r.namespaces
+	}
+	if r.defaultNamespace != "" {
+		return []string{r.defaultNamespace}
+	}
+	return nil
+}
+
+// writeAccess writes the access of a resource so the shell can display it
+func (r *ResourceWatcher) writeAccess(resourceName string, access resourceAccess) {
+	destDir := r.status.destDir
+	err := util.WriteStringToFile(access.String(), destDir, resourceName, "access")
+	if err != nil {
+		glog.Warningf("Error writing access of %s: %v", resourceName, err)
+	}
+}
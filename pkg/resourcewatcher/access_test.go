@@ -0,0 +1,183 @@
+package resourcewatcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAccessReviews allows list and watch of the given resource/namespace pairs, "" being cluster-wide.
+// Rules reviews of a namespace return the rules of the namespace and the cluster-wide ones
+func fakeAccessReviews(allowed map[string]bool) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = allowed[attributes.Resource+"/"+attributes.Namespace]
+		return true, review, nil
+	})
+	clientset.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectRulesReview)
+		for key, ok := range allowed {
+			parts := strings.Split(key, "/")
+			if ok && (parts[1] == "" || parts[1] == review.Spec.Namespace) {
+				review.Status.ResourceRules = append(review.Status.ResourceRules, authorizationv1.ResourceRule{
+					Verbs: []string{"list", "watch"}, APIGroups: []string{""}, Resources: []string{parts[0]},
+				})
+			}
+		}
+		return true, review, nil
+	})
+	return clientset
+}
+
+// countReviews returns the number of access and rules reviews created
+func countReviews(clientset *fake.Clientset) (int, int) {
+	accessReviews, rulesReviews := 0, 0
+	for _, action := range clientset.Actions() {
+		switch action.GetResource().Resource {
+		case "selfsubjectaccessreviews":
+			accessReviews++
+		case "selfsubjectrulesreviews":
+			rulesReviews++
+		}
+	}
+	return accessReviews, rulesReviews
+}
+
+func TestCheckAccess(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clientset := fakeAccessReviews(map[string]bool{
+		"pods/":        true,
+		"secrets/ns1":  true,
+		"secrets/ns3":  true,
+		"configmaps/":  false,
+		"nodes/":       false,
+		"services/ns2": true,
+	})
+	r := ResourceWatcher{
+		authorization:    clientset.AuthorizationV1(),
+		accessCache:      newAccessCache(),
+		namespaces:       []string{"ns1", "ns2", "ns3"},
+		namespacesListed: true,
+		status:           NewClusterStatus("test", tempDir),
+	}
+	ctx := context.Background()
+	podConfig := WatchConfig{k8sresources.NewPodFromRuntime, k8sresources.PodHeader, "pods", nil, &corev1.Pod{}, true, true, 0, nil}
+	secretConfig := WatchConfig{k8sresources.NewSecretFromRuntime, k8sresources.SecretHeader, "secrets", nil, &corev1.Secret{}, true, false, 0, nil}
+	configMapConfig := WatchConfig{k8sresources.NewConfigMapFromRuntime, k8sresources.ConfigMapHeader, "configmaps", nil, &corev1.ConfigMap{}, true, true, 0, nil}
+	nodeConfig := WatchConfig{k8sresources.NewNodeFromRuntime, k8sresources.NodeHeader, "nodes", nil, &corev1.Node{}, false, false, 1, nil}
+
+	assert.Equal(t, resourceAccess{mode: AccessCluster}, r.checkAccess(ctx, podConfig))
+	assert.Equal(t, resourceAccess{mode: AccessNamespaces, namespaces: []string{"ns1", "ns3"}}, r.checkAccess(ctx, secretConfig))
+	assert.Equal(t, resourceAccess{mode: AccessForbidden}, r.checkAccess(ctx, configMapConfig))
+	assert.Equal(t, resourceAccess{mode: AccessForbidden}, r.checkAccess(ctx, nodeConfig))
+	// The rules of each namespace are reviewed once
+	accessReviews, rulesReviews := countReviews(clientset)
+	assert.Equal(t, 5, accessReviews)
+	assert.Equal(t, 3, rulesReviews)
+
+	// Restarted resources use the cached reviews
+	assert.Equal(t, resourceAccess{mode: AccessNamespaces, namespaces: []string{"ns1", "ns3"}}, r.checkAccess(ctx, secretConfig))
+	accessReviews, rulesReviews = countReviews(clientset)
+	assert.Equal(t, 5, accessReviews)
+	assert.Equal(t, 3, rulesReviews)
+
+	// Without namespace listing, only the default namespace is checked
+	r.namespaces = nil
+	r.namespacesListed = false
+	r.defaultNamespace = "ns2"
+	serviceConfig := WatchConfig{k8sresources.NewServiceFromRuntime, k8sresources.ServiceHeader, "services", nil, &corev1.Service{}, true, false, 0, nil}
+	access := r.checkAccess(ctx, serviceConfig)
+	assert.Equal(t, resourceAccess{mode: AccessNamespaces, namespaces: []string{"ns2"}}, access)
+
+	r.setStatuses(serviceConfig, access, nil)
+	b, err := ioutil.ReadFile(path.Join(tempDir, "services_access"))
+	assert.Nil(t, err)
+	assert.Equal(t, "namespaces ns2", string(b))
+	assert.Equal(t, []string{"ns2"}, r.status.Resources["services"].Namespaces)
+}
+
+func TestRulesAllowListWatch(t *testing.T) {
+	var testDatas = []struct {
+		name     string
+		rules    []authorizationv1.ResourceRule
+		expected bool
+	}{
+		{"no rules", nil, false},
+		{"list and watch", []authorizationv1.ResourceRule{
+			{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		}, true},
+		{"verbs in separate rules", []authorizationv1.ResourceRule{
+			{Verbs: []string{"list"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+			{Verbs: []string{"watch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments", "replicasets"}},
+		}, true},
+		{"list only", []authorizationv1.ResourceRule{
+			{Verbs: []string{"list"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		}, false},
+		{"wildcards", []authorizationv1.ResourceRule{
+			{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		}, true},
+		{"other group", []authorizationv1.ResourceRule{
+			{Verbs: []string{"list", "watch"}, APIGroups: []string{""}, Resources: []string{"deployments"}},
+		}, false},
+		{"subresource", []authorizationv1.ResourceRule{
+			{Verbs: []string{"list", "watch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}},
+		}, false},
+		{"restricted names", []authorizationv1.ResourceRule{
+			{Verbs: []string{"list", "watch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{"web"}},
+		}, false},
+	}
+	for _, testData := range testDatas {
+		assert.Equal(t, testData.expected, rulesAllowListWatch(testData.rules, "apps", "deployments"), testData.name)
+	}
+}
+
+func TestStartForbiddenRemovesFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	destDir := path.Join(tempDir, "test")
+	assert.Nil(t, os.MkdirAll(destDir, os.ModePerm))
+	for _, name := range []string{"configmaps_resource", "configmaps_label", "configmaps_relations", "pods_resource"} {
+		assert.Nil(t, ioutil.WriteFile(path.Join(destDir, name), []byte("old"), 0644))
+	}
+
+	clientset := fakeAccessReviews(map[string]bool{})
+	r := ResourceWatcher{
+		authorization:    clientset.AuthorizationV1(),
+		accessCache:      newAccessCache(),
+		namespaces:       []string{"ns1"},
+		namespacesListed: true,
+		storeConfig:      StoreConfig{CacheDir: tempDir, ClusterDir: "test"},
+		started:          make(map[string]*runningResource),
+		status:           NewClusterStatus("test", destDir),
+	}
+	configMapConfig := WatchConfig{k8sresources.NewConfigMapFromRuntime, k8sresources.ConfigMapHeader, "configmaps", nil, &corev1.ConfigMap{}, true, true, 0, nil}
+	assert.Nil(t, r.Start(context.Background(), configMapConfig, k8sresources.CtorConfig{}))
+
+	for _, name := range []string{"configmaps_resource", "configmaps_label", "configmaps_relations"} {
+		_, err = os.Stat(path.Join(destDir, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+	_, err = os.Stat(path.Join(destDir, "pods_resource"))
+	assert.Nil(t, err)
+	b, err := ioutil.ReadFile(path.Join(destDir, "configmaps_access"))
+	assert.Nil(t, err)
+	assert.Equal(t, AccessForbidden, string(b))
+}
@@ -0,0 +1,386 @@
+package resourcewatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// journalDir is the dir of the journals in the cluster cache dir.
+// It's not matched by the resource patterns synced by rsync
+const journalDir = "journal"
+
+// minCompactionRecords is the number of records below which a journal is never compacted
+const minCompactionRecords = 1000
+
+// journalRecord is a line of a journal. A record holds an object, a deletion or only a resource version
+type journalRecord struct {
+	Type            string          `json:"type,omitempty"` // Type of the journaled objects, set in the first record
+	ResourceVersion string          `json:"rv,omitempty"`
+	Key             string          `json:"key,omitempty"`
+	Deleted         bool            `json:"deleted,omitempty"`
+	Object          json.RawMessage `json:"object,omitempty"`
+}
+
+// objectJournal persists the stripped objects of a watch and the last resource version seen
+// so the watch can be resumed after a restart without listing the resource.
+// A relist writes a snapshot, watch events are appended and the journal is compacted when
+// superseded records pile up. Journal failures only disable the resume.
+// The file is only opened while records are written, a watch per namespace would otherwise
+// keep a file descriptor open for each namespace
+type objectJournal struct {
+	filename      string
+	runtimeObject runtime.Object
+	objectType    string
+	appendable    bool // True once a snapshot was written, until the journal is closed
+	records       int
+	lastVersion   string
+	disabled      bool
+	mutex         sync.Mutex
+}
+
+func newObjectJournal(destDir string, resourceName string, namespace string, runtimeObject runtime.Object) *objectJournal {
+	name := fmt.Sprintf("%s_journal", resourceName)
+	if namespace != "" {
+		name = fmt.Sprintf("%s_%s_journal", resourceName, namespace)
+	}
+	return &objectJournal{
+		filename:      path.Join(destDir, journalDir, name),
+		runtimeObject: runtimeObject,
+		objectType:    fmt.Sprintf("%T", runtimeObject),
+	}
+}
+
+// fail disables the journal and removes it as it can't be trusted anymore. mutex needs to be held
+func (j *objectJournal) fail(err error) {
+	glog.Warningf("Disabling journal %s, the watch won't be resumed on restart: %v", j.filename, err)
+	j.disabled = true
+	j.appendable = false
+	os.Remove(j.filename)
+}
+
+// readRecords calls fn with each record of the journal and its index.
+// A truncated last record, left by an interrupted write, is ignored
+func (j *objectJournal) readRecords(fn func(i int, record *journalRecord, line []byte) error) error {
+	f, err := os.Open(j.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for i := 0; ; i++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				glog.Warningf("Ignoring truncated record at the end of %s", j.filename)
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Error reading %s", j.filename)
+		}
+		record := &journalRecord{}
+		err = json.Unmarshal(line, record)
+		if err != nil {
+			return errors.Wrapf(err, "Error parsing record %d of %s", i, j.filename)
+		}
+		if i == 0 && record.Type != j.objectType {
+			return errors.Errorf("%s holds %s objects instead of %s", j.filename, record.Type, j.objectType)
+		}
+		err = fn(i, record, line)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// load returns the journaled objects as a list at the last journaled resource version.
+// It returns nil if there's no usable journal
+func (j *objectJournal) load() (runtime.Object, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	objects := make(map[string]json.RawMessage)
+	resourceVersion := ""
+	records := 0
+	err := j.readRecords(func(i int, record *journalRecord, line []byte) error {
+		records++
+		if record.ResourceVersion != "" {
+			resourceVersion = record.ResourceVersion
+		}
+		if record.Key == "" {
+			return nil
+		}
+		if record.Deleted {
+			delete(objects, record.Key)
+		} else {
+			objects[record.Key] = record.Object
+		}
+		return nil
+	})
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resourceVersion == "" {
+		// The snapshot ends with its resource version, it was not completely written
+		return nil, nil
+	}
+
+	items := make([]runtime.Object, 0, len(objects))
+	for key, raw := range objects {
+		obj := j.runtimeObject.DeepCopyObject()
+		err = json.Unmarshal(raw, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error decoding %s from %s", key, j.filename)
+		}
+		items = append(items, obj)
+	}
+	list := &metav1.List{ListMeta: metav1.ListMeta{ResourceVersion: resourceVersion}}
+	err = apimeta.SetList(list, items)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error building list from %s", j.filename)
+	}
+	j.records = records
+	j.lastVersion = resourceVersion
+	return list, nil
+}
+
+// writeFile atomically replaces the journal with the records written by fn. mutex needs to be held
+func (j *objectJournal) writeFile(fn func(w *bufio.Writer) (int, error)) error {
+	dir := path.Dir(j.filename)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating journal dir %s", dir)
+	}
+	tempFile, err := ioutil.TempFile(dir, "_"+path.Base(j.filename))
+	if err != nil {
+		return errors.Wrapf(err, "Error creating temp file in %s", dir)
+	}
+	defer os.Remove(tempFile.Name())
+	w := bufio.NewWriter(tempFile)
+	records, err := fn(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tempFile.Close()
+		return errors.Wrapf(err, "Error writing %s", tempFile.Name())
+	}
+	err = tempFile.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Error closing %s", tempFile.Name())
+	}
+	err = os.Rename(tempFile.Name(), j.filename)
+	if err != nil {
+		return errors.Wrapf(err, "Error renaming %s", tempFile.Name())
+	}
+	j.appendable = true
+	j.records = records
+	return nil
+}
+
+func writeRecord(w io.Writer, record *journalRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// writeSnapshot replaces the journal with the objects of a listing
+func (j *objectJournal) writeSnapshot(objects []interface{}, resourceVersion string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.disabled {
+		return
+	}
+	err := j.writeFile(func(w *bufio.Writer) (int, error) {
+		err := writeRecord(w, &journalRecord{Type: j.objectType})
+		if err != nil {
+			return 0, err
+		}
+		for _, obj := range objects {
+			key, _, _ := resourceKey(obj)
+			raw, err := json.Marshal(obj)
+			if err != nil {
+				return 0, errors.Wrapf(err, "Error encoding %s", key)
+			}
+			err = writeRecord(w, &journalRecord{Key: key, Object: raw})
+			if err != nil {
+				return 0, err
+			}
+		}
+		// The resource version is written last, a snapshot without it is incomplete
+		err = writeRecord(w, &journalRecord{ResourceVersion: resourceVersion})
+		return len(objects) + 2, err
+	})
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	j.lastVersion = resourceVersion
+	glog.V(6).Infof("Wrote snapshot of %d objects at %s in %s", len(objects), resourceVersion, j.filename)
+}
+
+// append writes a record after the last snapshot. mutex needs to be held
+func (j *objectJournal) append(record *journalRecord) {
+	if j.disabled || !j.appendable {
+		// Events are only journaled once a snapshot was written
+		return
+	}
+	f, err := os.OpenFile(j.filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		j.fail(errors.Wrapf(err, "Error opening %s", j.filename))
+		return
+	}
+	err = writeRecord(f, record)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	j.records++
+	if record.ResourceVersion != "" {
+		j.lastVersion = record.ResourceVersion
+	}
+}
+
+func objectResourceVersion(obj interface{}) string {
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetResourceVersion()
+}
+
+// addObject journals an added or modified object
+func (j *objectJournal) addObject(obj interface{}, liveObjects int) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	key, _, _ := resourceKey(obj)
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		j.fail(errors.Wrapf(err, "Error encoding %s", key))
+		return
+	}
+	j.append(&journalRecord{ResourceVersion: objectResourceVersion(obj), Key: key, Object: raw})
+	j.compactIfNeeded(liveObjects)
+}
+
+// deleteObject journals a tombstone for a deleted object
+func (j *objectJournal) deleteObject(key string, obj interface{}, liveObjects int) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.append(&journalRecord{ResourceVersion: objectResourceVersion(obj), Key: key, Deleted: true})
+	j.compactIfNeeded(liveObjects)
+}
+
+// setResourceVersion journals a resource version received without object, like a bookmark
+func (j *objectJournal) setResourceVersion(resourceVersion string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if resourceVersion == j.lastVersion {
+		return
+	}
+	j.append(&journalRecord{ResourceVersion: resourceVersion})
+}
+
+// compactIfNeeded rewrites the journal with only the last record of each live object
+// once most of its records are superseded. mutex needs to be held
+func (j *objectJournal) compactIfNeeded(liveObjects int) {
+	if j.disabled || j.records < minCompactionRecords || j.records < 2*(liveObjects+1) {
+		return
+	}
+	// First pass finds the last record of each key, the second copies them
+	last := make(map[string]int)
+	err := j.readRecords(func(i int, record *journalRecord, line []byte) error {
+		if record.Key == "" {
+			return nil
+		}
+		if record.Deleted {
+			delete(last, record.Key)
+		} else {
+			last[record.Key] = i
+		}
+		return nil
+	})
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	previousRecords := j.records
+	err = j.writeFile(func(w *bufio.Writer) (int, error) {
+		records := 0
+		err := j.readRecords(func(i int, record *journalRecord, line []byte) error {
+			if i != 0 && (record.Key == "" || last[record.Key] != i) {
+				return nil
+			}
+			records++
+			_, err := w.Write(line)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		err = writeRecord(w, &journalRecord{ResourceVersion: j.lastVersion})
+		return records + 1, err
+	})
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	glog.V(6).Infof("Compacted %s from %d to %d records", j.filename, previousRecords, j.records)
+}
+
+// close stops journaling the events of the stopped watch
+func (j *objectJournal) close() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.appendable = false
+}
+
+// resumeListerWatcher serves the first listing from the journal so the watch resumes
+// from the journaled resource version. Following listings, like the relist after a
+// 410 Gone, are done by the wrapped ListerWatcher
+type resumeListerWatcher struct {
+	cache.ListerWatcher
+	journal *objectJournal
+	resumed bool
+}
+
+func newResumeListerWatcher(lw cache.ListerWatcher, journal *objectJournal) *resumeListerWatcher {
+	return &resumeListerWatcher{ListerWatcher: lw, journal: journal}
+}
+
+// List returns the journaled objects on the first call and lists the resource afterwards
+func (l *resumeListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	if !l.resumed {
+		l.resumed = true
+		list, err := l.journal.load()
+		if err != nil {
+			glog.Warningf("Listing instead of resuming: %v", err)
+		} else if list != nil {
+			glog.Infof("Resuming %s from its journal", l.journal.filename)
+			return list, nil
+		}
+	}
+	return l.ListerWatcher.List(options)
+}
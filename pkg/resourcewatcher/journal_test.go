@@ -0,0 +1,217 @@
+package resourcewatcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func podWithVersion(name string, resourceVersion string) *corev1.Pod {
+	pod := podResource(name, "ns1", nil)
+	pod.ResourceVersion = resourceVersion
+	return &pod
+}
+
+// runJournaledReflector feeds a new pod store through a reflector resuming from the journal in the fixture's temp dir
+func runJournaledReflector(fixture *storeFixture, lw *fakeListerWatcher, stop chan struct{}) *K8sStore {
+	k := fixture.newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{})
+	journal := newObjectJournal(fixture.tempDir, "pods", "", &corev1.Pod{})
+	store := newSummaryStore(k, "", k8sresources.StripObject, journal)
+	k.startListing([]string{""})
+	reflector := cache.NewReflector(newResumeListerWatcher(lw, journal), &corev1.Pod{}, store, 0)
+	go func() {
+		reflector.Run(stop)
+		journal.close()
+	}()
+	return k
+}
+
+func TestResumeWatch(t *testing.T) {
+	fixture := newStoreFixture(t)
+
+	// First run lists the pods and journals the events
+	lw := &fakeListerWatcher{watchers: make(chan *watch.FakeWatcher, 1)}
+	lw.setPods(*podWithVersion("a", "1"), *podWithVersion("b", "1"))
+	stop := make(chan struct{})
+	k := runJournaledReflector(fixture, lw, stop)
+	w := <-lw.watchers
+	w.Add(podWithVersion("c", "2"))
+	w.Delete(podWithVersion("b", "3"))
+	w.Action(watch.Bookmark, podWithVersion("", "4"))
+	assert.Eventually(t, func() bool {
+		names := storePodNames(k)
+		return len(names) == 2 && names[1] == "ns1_c"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		b, err := ioutil.ReadFile(path.Join(fixture.tempDir, journalDir, "pods_journal"))
+		return err == nil && strings.Contains(string(b), `{"rv":"4"}`)
+	}, 5*time.Second, 10*time.Millisecond)
+	close(stop)
+
+	// Second run resumes from the journal without listing
+	lw = &fakeListerWatcher{watchers: make(chan *watch.FakeWatcher, 1)}
+	lw.setPods(*podWithVersion("d", "5"))
+	stop = make(chan struct{})
+	defer close(stop)
+	k = runJournaledReflector(fixture, lw, stop)
+	w = <-lw.watchers
+	assert.Equal(t, []string{"ns1_a", "ns1_c"}, storePodNames(k))
+	assert.Equal(t, StateSynced, k.syncStatus().State)
+	lw.mutex.Lock()
+	assert.Empty(t, lw.continues)
+	assert.Equal(t, []string{"4"}, lw.versions)
+	lw.mutex.Unlock()
+
+	// The journaled version expired, the pods are listed
+	w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired})
+	<-lw.watchers
+	assert.Equal(t, []string{"ns1_d"}, storePodNames(k))
+	lw.mutex.Lock()
+	assert.Equal(t, []string{""}, lw.continues)
+	lw.mutex.Unlock()
+}
+
+func TestJournalCompaction(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	j := newObjectJournal(tempDir, "pods", "ns1", &corev1.Pod{})
+	list, err := j.load()
+	assert.Nil(t, err)
+	assert.Nil(t, list)
+
+	j.writeSnapshot([]interface{}{podWithVersion("a", "1"), podWithVersion("b", "1")}, "1")
+	for i := 2; i < 2*minCompactionRecords; i++ {
+		j.addObject(podWithVersion("a", strconv.Itoa(i)), 2)
+	}
+	j.deleteObject("ns1_b", podWithVersion("b", "5000"), 1)
+	j.setResourceVersion("5001")
+	assert.Less(t, j.records, minCompactionRecords)
+	j.close()
+
+	// A record interrupted by a crash is ignored
+	f, err := os.OpenFile(j.filename, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString(`{"rv":"5002","key":"ns1_c","obj`)
+	assert.Nil(t, err)
+	f.Close()
+
+	j = newObjectJournal(tempDir, "pods", "ns1", &corev1.Pod{})
+	list, err = j.load()
+	assert.Nil(t, err)
+	listMeta, err := apimeta.ListAccessor(list)
+	assert.Nil(t, err)
+	assert.Equal(t, "5001", listMeta.GetResourceVersion())
+	items, err := apimeta.ExtractList(list)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	pod := items[0].(*corev1.Pod)
+	assert.Equal(t, "a", pod.Name)
+	assert.Equal(t, strconv.Itoa(2*minCompactionRecords-1), pod.ResourceVersion)
+
+	// A journal of another type is not loaded
+	j = newObjectJournal(tempDir, "pods", "ns1", &metav1.PartialObjectMetadata{})
+	_, err = j.load()
+	assert.NotNil(t, err)
+}
+
+// openFiles returns the number of open file descriptors of the process
+func openFiles(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("Open files can't be counted:", err)
+	}
+	return len(fds)
+}
+
+func TestJournalManyNamespaces(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	openedFiles := openFiles(t)
+	journals := make([]*objectJournal, 2000)
+	for i := range journals {
+		namespace := fmt.Sprintf("ns%d", i)
+		journals[i] = newObjectJournal(tempDir, "pods", namespace, &corev1.Pod{})
+		journals[i].writeSnapshot([]interface{}{podWithVersion("a", "1")}, "1")
+		journals[i].addObject(podWithVersion("b", "2"), 2)
+	}
+	// Watches of every namespace journal events without keeping their journal open
+	assert.LessOrEqual(t, openFiles(t), openedFiles+1)
+	for _, j := range journals {
+		j.addObject(podWithVersion("c", "3"), 3)
+		j.close()
+	}
+	assert.LessOrEqual(t, openFiles(t), openedFiles+1)
+
+	for i := range journals {
+		j := newObjectJournal(tempDir, "pods", fmt.Sprintf("ns%d", i), &corev1.Pod{})
+		list, err := j.load()
+		assert.Nil(t, err)
+		items, err := apimeta.ExtractList(list)
+		assert.Nil(t, err)
+		assert.Len(t, items, 3)
+	}
+}
+
+func TestJournalHelmRelease(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	secret := helmReleaseSecret(t, "web", 1, "superseded")
+	payload := string(secret.Data["release"])
+	expected := k8sresources.NewHelmReleaseFromRuntime(secret.DeepCopy(), k8sresources.CtorConfig{}).ToString()
+
+	j := newObjectJournal(tempDir, "helmreleases", "ns1", &corev1.Secret{})
+	stripped, err := k8sresources.StripObject(secret.DeepCopy())
+	assert.Nil(t, err)
+	j.writeSnapshot([]interface{}{stripped}, "1")
+	updated := helmReleaseSecret(t, "web", 2, "deployed")
+	stripped, err = k8sresources.StripObject(updated.DeepCopy())
+	assert.Nil(t, err)
+	j.addObject(stripped, 2)
+	j.close()
+
+	// Only the metadata of the release is journaled
+	b, err := ioutil.ReadFile(j.filename)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), payload)
+	assert.NotContains(t, string(b), string(updated.Data["release"]))
+	j = newObjectJournal(tempDir, "helmreleases", "ns1", &corev1.Secret{})
+	list, err := j.load()
+	assert.Nil(t, err)
+	items, err := apimeta.ExtractList(list)
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	for _, item := range items {
+		release, err := base64.StdEncoding.DecodeString(string(item.(*corev1.Secret).Data["release"]))
+		assert.Nil(t, err)
+		assert.NotContains(t, string(release), "manifest")
+		assert.NotContains(t, string(release), "hunter2")
+	}
+
+	// The summary of a resumed release is unchanged
+	summaries := make([]string, 0, len(items))
+	for _, item := range items {
+		summaries = append(summaries, k8sresources.NewHelmReleaseFromRuntime(item, k8sresources.CtorConfig{}).ToString())
+	}
+	assert.Contains(t, summaries, expected)
+}
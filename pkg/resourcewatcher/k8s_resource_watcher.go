@@ -2,8 +2,10 @@ package resourcewatcher
 
 import (
 	"context"
+	"math"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"kubectlfzf/pkg/k8sresources"
@@ -11,17 +13,26 @@ import (
 	"regexp"
 
 	"github.com/golang/glog"
+	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batchv1 "k8s.io/api/batch/v1"
 	batchbetav1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	betav1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	typedauthorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/metadata"
 
 	// Import for oidc auth
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
@@ -31,12 +42,100 @@ import (
 
 // ResourceWatcher contains rest clients for a given kubernetes context
 type ResourceWatcher struct {
-	clientset          *kubernetes.Clientset
-	namespaces         []string // List of namespaces filtered using excludedNamespaces
+	clientset          kubernetes.Interface
+	metadataClient     metadata.Interface
+	authorization      typedauthorizationv1.AuthorizationV1Interface
+	accessCache        *accessCache // Reviewed permissions, kept across restarts of resources
+	namespaces         []string     // List of namespaces filtered using excludedNamespaces
+	namespacesListed   bool         // False if namespaces couldn't be listed
+	defaultNamespace   string       // Namespace checked when namespaces can't be listed
 	excludedNamespaces []*regexp.Regexp
 	cluster            string
 	cancelFuncs        []context.CancelFunc
+	started            map[string]*runningResource // Started resources, including forbidden and failed ones
 	storeConfig        StoreConfig
+	stores             *storeLookup
+	status             *ClusterStatus
+	listPageSize       int64 // Number of objects per listing page, 0 lists everything at once
+
+	metadataOnlyResources map[string]bool // Resources watched without their spec and data
+}
+
+// pollRetryDelay is the first delay before retrying a failed poll
+var pollRetryDelay = 10 * time.Second
+
+// metadataOnlyCapableResources are the resources whose summary can be built from metadata only
+var metadataOnlyCapableResources = map[string]schema.GroupVersionResource{
+	"configmaps": corev1.SchemeGroupVersion.WithResource("configmaps"),
+	"secrets":    corev1.SchemeGroupVersion.WithResource("secrets"),
+}
+
+// storeLookup gives access to the stores of all watched resources
+type storeLookup struct {
+	stores map[string]*K8sStore
+	mutex  sync.Mutex
+}
+
+func (s *storeLookup) addStore(store *K8sStore) {
+	s.mutex.Lock()
+	s.stores[store.resourceName] = store
+	s.mutex.Unlock()
+}
+
+// removeStores removes the stores of resources which aren't watched anymore
+func (s *storeLookup) removeStores(resourceNames []string) {
+	s.mutex.Lock()
+	for _, resourceName := range resourceNames {
+		delete(s.stores, resourceName)
+	}
+	s.mutex.Unlock()
+}
+
+// GetResource returns the resource with the given key from the store of resourceName
+func (s *storeLookup) GetResource(resourceName string, key string) (k8sresources.K8sResource, bool) {
+	s.mutex.Lock()
+	store, ok := s.stores[resourceName]
+	s.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return store.GetResource(key)
+}
+
+// GetIndexedResources returns the resources of resourceName having the value in the given index
+func (s *storeLookup) GetIndexedResources(resourceName string, indexName string, value string) []k8sresources.K8sResource {
+	s.mutex.Lock()
+	store, ok := s.stores[resourceName]
+	s.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return store.GetIndexedResources(indexName, value)
+}
+
+// runningResource is a started resource with the watches of its namespaces
+type runningResource struct {
+	cfg        WatchConfig
+	ctorConfig k8sresources.CtorConfig
+	access     resourceAccess
+	store      *K8sStore // Nil if the resource is forbidden or failed to start
+	ctx        context.Context
+	cancel     context.CancelFunc
+	split      bool                       // Watched namespaces follow the namespaces of the watcher
+	namespaces map[string]*namespaceWatch // Watches of each namespace, "" for cluster-wide
+	wg         sync.WaitGroup             // Running watches and polls
+}
+
+// namespaceWatch is the watch of a resource in a namespace
+type namespaceWatch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop cancels the watch and waits for its reflector to exit
+func (w *namespaceWatch) stop() {
+	w.cancel()
+	<-w.done
 }
 
 // WatchConfig provides the configuration to watch a specific kubernetes resource
@@ -49,94 +148,272 @@ type WatchConfig struct {
 	hasNamespace      bool
 	splitByNamespaces bool
 	pollingPeriod     time.Duration
+	derivedConfigs    []WatchConfig // Resources built from the same informer events
 }
 
 // NewResourceWatcher creates a new resource watcher on a given cluster
 func NewResourceWatcher(config *restclient.Config,
-	storeConfig StoreConfig, excludedNamespaces []string) ResourceWatcher {
+	storeConfig StoreConfig, excludedNamespaces []string, listPageSize int64) (ResourceWatcher, error) {
 	var err error
 	resourceWatcher := ResourceWatcher{}
 	resourceWatcher.clientset, err = kubernetes.NewForConfig(config)
-	util.FatalIf(err)
+	if err != nil {
+		return resourceWatcher, errors.Wrapf(err, "Error creating client for %s", config.Host)
+	}
+	resourceWatcher.metadataClient, err = metadata.NewForConfig(config)
+	if err != nil {
+		return resourceWatcher, errors.Wrapf(err, "Error creating metadata client for %s", config.Host)
+	}
+	resourceWatcher.authorization = resourceWatcher.clientset.AuthorizationV1()
+	resourceWatcher.accessCache = newAccessCache()
 	resourceWatcher.storeConfig = storeConfig
-	resourceWatcher.excludedNamespaces = make([]*regexp.Regexp, len(excludedNamespaces))
+	resourceWatcher.listPageSize = listPageSize
+	resourceWatcher.stores = &storeLookup{stores: make(map[string]*K8sStore)}
+	resourceWatcher.started = make(map[string]*runningResource)
+	resourceWatcher.status = NewClusterStatus(storeConfig.ClusterDir, path.Join(storeConfig.CacheDir, storeConfig.ClusterDir))
+	resourceWatcher.excludedNamespaces, err = compileExcludedNamespaces(excludedNamespaces)
+	if err != nil {
+		return resourceWatcher, err
+	}
+	glog.Infof("%d Namespaces will be excluded: %s", len(excludedNamespaces), excludedNamespaces)
+	return resourceWatcher, nil
+}
+
+func compileExcludedNamespaces(excludedNamespaces []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(excludedNamespaces))
 	for i, ns := range excludedNamespaces {
 		rg, err := regexp.Compile(ns)
-		util.FatalIf(err)
-		resourceWatcher.excludedNamespaces[i] = rg
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid excluded namespace %s", ns)
+		}
+		res[i] = rg
 	}
-	glog.Infof("%d Namespaces will be excluded: %s", len(excludedNamespaces), excludedNamespaces)
-	return resourceWatcher
+	return res, nil
 }
 
-// Start begins the watch/poll of a given k8s resource
-func (r *ResourceWatcher) Start(parentCtx context.Context, cfg WatchConfig, ctorConfig k8sresources.CtorConfig) error {
-	ctx, cancel := context.WithCancel(parentCtx)
-	r.cancelFuncs = append(r.cancelFuncs, cancel)
+// setStatus records the state of a resource, failures to write the status file are only logged
+func (r *ResourceWatcher) setStatus(resourceName string, access resourceAccess, err error) {
+	var statusErr error
+	switch {
+	case err != nil:
+		statusErr = r.status.SetError(resourceName, err)
+	case access.mode == AccessForbidden:
+		statusErr = r.status.SetForbidden(resourceName)
+	default:
+		statusErr = r.status.SetListing(resourceName, access.mode, access.namespaces)
+	}
+	if statusErr != nil {
+		glog.Warningf("Error writing status of %s: %v", resourceName, statusErr)
+	}
+}
 
-	if cfg.pollingPeriod > 0 {
-		store, err := NewK8sStore(ctx, cfg, r.storeConfig, ctorConfig)
-		if err != nil {
-			return err
+// setStatuses records the state and the access of a resource and of its derived resources
+func (r *ResourceWatcher) setStatuses(cfg WatchConfig, access resourceAccess, err error) {
+	for _, resourceName := range append([]string{cfg.resourceName}, derivedResourceNames(cfg)...) {
+		r.setStatus(resourceName, access, err)
+		if err == nil {
+			r.writeAccess(resourceName, access)
 		}
-		go r.pollResource(ctx, cfg, store)
-		return nil
 	}
+}
 
-	if cfg.splitByNamespaces {
-		glog.Infof("Starting watcher for ns %v, resource %s", r.namespaces, cfg.resourceName)
-		store, err := NewK8sStore(ctx, cfg, r.storeConfig, ctorConfig)
-		if err != nil {
-			return err
+func derivedResourceNames(cfg WatchConfig) []string {
+	res := make([]string, len(cfg.derivedConfigs))
+	for i, derivedConfig := range cfg.derivedConfigs {
+		res[i] = derivedConfig.resourceName
+	}
+	return res
+}
+
+// Start begins the watch/poll of a given k8s resource.
+// Resources which can't be listed cluster-wide are watched in the accessible namespaces or skipped.
+// A resource failing to start is marked as degraded in the status file
+func (r *ResourceWatcher) Start(parentCtx context.Context, cfg WatchConfig, ctorConfig k8sresources.CtorConfig) error {
+	res := &runningResource{cfg: cfg, ctorConfig: ctorConfig, namespaces: make(map[string]*namespaceWatch)}
+	r.started[cfg.resourceName] = res
+	access := r.checkAccess(parentCtx, cfg)
+	res.access = access
+	if access.mode == AccessForbidden {
+		glog.Infof("Skipping %s, it can't be listed and watched", cfg.resourceName)
+		// Files from a previous run would be served as if the resource was still watched
+		for _, resourceName := range append([]string{cfg.resourceName}, derivedResourceNames(cfg)...) {
+			removeResourceFiles(path.Join(r.storeConfig.CacheDir, r.storeConfig.ClusterDir), resourceName)
 		}
-		go r.watchResource(ctx, cfg, store, r.namespaces)
+		r.setStatuses(cfg, access, nil)
 		return nil
 	}
 
-	store, err := NewK8sStore(ctx, cfg, r.storeConfig, ctorConfig)
+	res.ctx, res.cancel = context.WithCancel(parentCtx)
+	store, err := r.newStore(res.ctx, cfg, ctorConfig)
 	if err != nil {
+		res.cancel()
+		err = errors.Wrapf(err, "Error starting %s", cfg.resourceName)
+		r.setStatuses(cfg, access, err)
 		return err
 	}
-	go r.watchResource(ctx, cfg, store, []string{""})
+	res.store = store
+	r.setStatuses(cfg, access, nil)
+
+	if cfg.pollingPeriod > 0 {
+		store.startListing([]string{""})
+		res.wg.Add(1)
+		go func() {
+			defer res.wg.Done()
+			r.pollResource(res.ctx, cfg, store)
+		}()
+		return nil
+	}
+
+	namespaces := []string{""}
+	if access.mode == AccessNamespaces {
+		namespaces = access.namespaces
+		glog.Infof("Starting watcher for accessible ns %v, resource %s", namespaces, cfg.resourceName)
+	} else if cfg.splitByNamespaces && r.namespacesListed {
+		namespaces = r.namespaces
+		res.split = true
+		glog.Infof("Starting watcher for ns %v, resource %s", namespaces, cfg.resourceName)
+	}
+	store.startListing(namespaces)
+	for _, namespace := range namespaces {
+		r.watchNamespace(res, namespace)
+	}
 	return nil
 }
 
-// Stop closes the watch/poll process of a k8s resource
+// StartStatusDump regularly writes the sync state of the watched resources in the status file
+func (r *ResourceWatcher) StartStatusDump(parentCtx context.Context) {
+	ctx, cancel := context.WithCancel(parentCtx)
+	r.cancelFuncs = append(r.cancelFuncs, cancel)
+	go func() {
+		ticker := time.NewTicker(statusDumpPeriod)
+		defer ticker.Stop()
+		for {
+			r.dumpStatus()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// dumpStatus refreshes the status of the started resources and writes the status file
+func (r *ResourceWatcher) dumpStatus() {
+	r.stores.mutex.Lock()
+	stores := make([]*K8sStore, 0, len(r.stores.stores))
+	for _, store := range r.stores.stores {
+		stores = append(stores, store)
+	}
+	r.stores.mutex.Unlock()
+	for _, store := range stores {
+		r.status.update(store.resourceName, store.syncStatus())
+	}
+	err := r.status.Write()
+	if err != nil {
+		glog.Warningf("Error writing status: %v", err)
+	}
+}
+
+// newStore creates the store of a resource and the stores of its derived resources
+func (r *ResourceWatcher) newStore(ctx context.Context, cfg WatchConfig, ctorConfig k8sresources.CtorConfig) (*K8sStore, error) {
+	store, err := NewK8sStore(ctx, cfg, r.storeConfig, ctorConfig)
+	if err != nil {
+		return nil, err
+	}
+	store.lookup = r.stores
+	r.stores.addStore(store)
+	for _, derivedConfig := range cfg.derivedConfigs {
+		derivedStore, err := NewK8sStore(ctx, derivedConfig, r.storeConfig, ctorConfig)
+		if err != nil {
+			return nil, err
+		}
+		derivedStore.lookup = r.stores
+		r.stores.addStore(derivedStore)
+		store.derivedStores = append(store.derivedStores, derivedStore)
+	}
+	return store, nil
+}
+
+// Stop closes the watch/poll processes of the resources and waits for them to exit
 func (r *ResourceWatcher) Stop() {
-	glog.Infof("Stopping %d resource watcher", len(r.cancelFuncs))
+	glog.Infof("Stopping %d resource watcher", len(r.started))
+	for _, res := range r.started {
+		if res.cancel != nil {
+			res.cancel()
+		}
+	}
 	for _, cancel := range r.cancelFuncs {
 		cancel()
 	}
+	// A watcher restarted on the same cluster writes the same files
+	for _, res := range r.started {
+		res.wg.Wait()
+		if res.store != nil {
+			res.store.closeFiles()
+		}
+	}
 }
 
 // GetWatchConfigs creates the list of k8s to watch
-func (r *ResourceWatcher) GetWatchConfigs(nodePollingPeriod time.Duration, namespacePollingPeriod time.Duration, excludedResources []string) []WatchConfig {
+func (r *ResourceWatcher) GetWatchConfigs(nodePollingPeriod time.Duration, namespacePollingPeriod time.Duration, excludedResources []string, metadataOnlyResources []string) []WatchConfig {
+	r.metadataOnlyResources = make(map[string]bool)
+	for _, resourceName := range metadataOnlyResources {
+		if _, ok := metadataOnlyCapableResources[resourceName]; !ok {
+			glog.Warningf("%s can't be watched with metadata only, supported resources: configmaps secrets", resourceName)
+			continue
+		}
+		r.metadataOnlyResources[resourceName] = true
+	}
+	glog.Infof("%d Resources will be watched with metadata only: %v", len(r.metadataOnlyResources), metadataOnlyResources)
 	coreGetter := r.clientset.CoreV1().RESTClient()
 	appsGetter := r.clientset.AppsV1().RESTClient()
-	autoscalingGetter := r.clientset.AutoscalingV1().RESTClient()
-	betaGetter := r.clientset.ExtensionsV1beta1().RESTClient()
-	batchGetterV1Beta := r.clientset.BatchV1beta1().RESTClient()
+	var autoscalingGetter cache.Getter = r.clientset.AutoscalingV1().RESTClient()
+	var hpaObject runtime.Object = &autoscalingv1.HorizontalPodAutoscaler{}
+	if r.isResourceServed("autoscaling/v2", "horizontalpodautoscalers") {
+		autoscalingGetter = r.clientset.AutoscalingV2().RESTClient()
+		hpaObject = &autoscalingv2.HorizontalPodAutoscaler{}
+	} else if r.isResourceServed("autoscaling/v2beta2", "horizontalpodautoscalers") {
+		autoscalingGetter = r.clientset.AutoscalingV2beta2().RESTClient()
+		hpaObject = &autoscalingv2beta2.HorizontalPodAutoscaler{}
+	}
+	var ingressGetter cache.Getter = r.clientset.NetworkingV1().RESTClient()
+	var ingressObject runtime.Object = &networkingv1.Ingress{}
+	if !r.isResourceServed("networking.k8s.io/v1", "ingresses") {
+		ingressGetter = r.clientset.ExtensionsV1beta1().RESTClient()
+		ingressObject = &betav1.Ingress{}
+	}
 	batchGetter := r.clientset.BatchV1().RESTClient()
+	var cronJobGetter cache.Getter = batchGetter
+	var cronJobObject runtime.Object = &batchv1.CronJob{}
+	if !r.isResourceServed("batch/v1", "cronjobs") {
+		cronJobGetter = r.clientset.BatchV1beta1().RESTClient()
+		cronJobObject = &batchbetav1.CronJob{}
+	}
 
 	allWatchConfigs := []WatchConfig{
-		{k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), coreGetter, &corev1.Pod{}, true, true, 0},
-		{k8sresources.NewConfigMapFromRuntime, k8sresources.ConfigMapHeader, "configmaps", coreGetter, &corev1.ConfigMap{}, true, true, 0},
-		{k8sresources.NewServiceFromRuntime, k8sresources.ServiceHeader, string(corev1.ResourceServices), coreGetter, &corev1.Service{}, true, false, 0},
-		{k8sresources.NewServiceAccountFromRuntime, k8sresources.ServiceAccountHeader, "serviceaccounts", coreGetter, &corev1.ServiceAccount{}, true, false, 0},
-		{k8sresources.NewReplicaSetFromRuntime, k8sresources.ReplicaSetHeader, "replicasets", appsGetter, &appsv1.ReplicaSet{}, true, false, 0},
-		{k8sresources.NewDaemonSetFromRuntime, k8sresources.DaemonSetHeader, "daemonsets", appsGetter, &appsv1.DaemonSet{}, true, false, 0},
-		{k8sresources.NewSecretFromRuntime, k8sresources.SecretHeader, "secrets", coreGetter, &corev1.Secret{}, true, false, 0},
-		{k8sresources.NewStatefulSetFromRuntime, k8sresources.StatefulSetHeader, "statefulsets", appsGetter, &appsv1.StatefulSet{}, true, false, 0},
-		{k8sresources.NewDeploymentFromRuntime, k8sresources.DeploymentHeader, "deployments", appsGetter, &appsv1.Deployment{}, true, false, 0},
-		{k8sresources.NewEndpointsFromRuntime, k8sresources.EndpointsHeader, "endpoints", coreGetter, &corev1.Endpoints{}, true, false, 0},
-		{k8sresources.NewIngressFromRuntime, k8sresources.IngressHeader, "ingresses", betaGetter, &betav1.Ingress{}, true, false, 0},
-		{k8sresources.NewCronJobFromRuntime, k8sresources.CronJobHeader, "cronjobs", batchGetterV1Beta, &batchbetav1.CronJob{}, true, false, 0},
-		{k8sresources.NewJobFromRuntime, k8sresources.JobHeader, "jobs", batchGetter, &batchv1.Job{}, true, false, 0},
-		{k8sresources.NewHpaFromRuntime, k8sresources.HpaHeader, "horizontalpodautoscalers", autoscalingGetter, &autoscalingv1.HorizontalPodAutoscaler{}, true, false, 0},
-		{k8sresources.NewPersistentVolumeFromRuntime, k8sresources.PersistentVolumeHeader, "persistentvolumes", coreGetter, &corev1.PersistentVolume{}, false, false, 0},
-		{k8sresources.NewPersistentVolumeClaimFromRuntime, k8sresources.PersistentVolumeClaimHeader, string(corev1.ResourcePersistentVolumeClaims), coreGetter, &corev1.PersistentVolumeClaim{}, true, false, 0},
-		{k8sresources.NewNodeFromRuntime, k8sresources.NodeHeader, "nodes", coreGetter, &corev1.Node{}, false, false, nodePollingPeriod},
-		{k8sresources.NewNamespaceFromRuntime, k8sresources.NamespaceHeader, "namespaces", coreGetter, &corev1.Namespace{}, false, false, namespacePollingPeriod},
+		{k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), coreGetter, &corev1.Pod{}, true, true, 0, []WatchConfig{
+			{k8sresources.NewPodContainersFromRuntime, k8sresources.ContainerHeader, "containers", nil, &corev1.Pod{}, true, true, 0, nil},
+		}},
+		{k8sresources.NewConfigMapFromRuntime, k8sresources.ConfigMapHeader, "configmaps", coreGetter, &corev1.ConfigMap{}, true, true, 0, nil},
+		{k8sresources.NewServiceFromRuntime, k8sresources.ServiceHeader, string(corev1.ResourceServices), coreGetter, &corev1.Service{}, true, false, 0, nil},
+		{k8sresources.NewServiceAccountFromRuntime, k8sresources.ServiceAccountHeader, "serviceaccounts", coreGetter, &corev1.ServiceAccount{}, true, false, 0, nil},
+		{k8sresources.NewReplicaSetFromRuntime, k8sresources.ReplicaSetHeader, "replicasets", appsGetter, &appsv1.ReplicaSet{}, true, false, 0, nil},
+		{k8sresources.NewDaemonSetFromRuntime, k8sresources.DaemonSetHeader, "daemonsets", appsGetter, &appsv1.DaemonSet{}, true, false, 0, nil},
+		{k8sresources.NewSecretFromRuntime, k8sresources.SecretHeader, "secrets", coreGetter, &corev1.Secret{}, true, false, 0, []WatchConfig{
+			{k8sresources.NewHelmReleaseFromRuntime, k8sresources.HelmReleaseHeader, "helmreleases", nil, &corev1.Secret{}, true, false, 0, nil},
+		}},
+		{k8sresources.NewStatefulSetFromRuntime, k8sresources.StatefulSetHeader, "statefulsets", appsGetter, &appsv1.StatefulSet{}, true, false, 0, nil},
+		{k8sresources.NewDeploymentFromRuntime, k8sresources.DeploymentHeader, "deployments", appsGetter, &appsv1.Deployment{}, true, false, 0, nil},
+		{k8sresources.NewEndpointsFromRuntime, k8sresources.EndpointsHeader, "endpoints", coreGetter, &corev1.Endpoints{}, true, false, 0, nil},
+		{k8sresources.NewIngressFromRuntime, k8sresources.IngressHeader, "ingresses", ingressGetter, ingressObject, true, false, 0, nil},
+		{k8sresources.NewCronJobFromRuntime, k8sresources.CronJobHeader, "cronjobs", cronJobGetter, cronJobObject, true, false, 0, nil},
+		{k8sresources.NewJobFromRuntime, k8sresources.JobHeader, "jobs", batchGetter, &batchv1.Job{}, true, false, 0, nil},
+		{k8sresources.NewHpaFromRuntime, k8sresources.HpaHeader, "horizontalpodautoscalers", autoscalingGetter, hpaObject, true, false, 0, nil},
+		{k8sresources.NewPersistentVolumeFromRuntime, k8sresources.PersistentVolumeHeader, "persistentvolumes", coreGetter, &corev1.PersistentVolume{}, false, false, 0, nil},
+		{k8sresources.NewPersistentVolumeClaimFromRuntime, k8sresources.PersistentVolumeClaimHeader, string(corev1.ResourcePersistentVolumeClaims), coreGetter, &corev1.PersistentVolumeClaim{}, true, false, 0, nil},
+		{k8sresources.NewNodeFromRuntime, k8sresources.NodeHeader, "nodes", coreGetter, &corev1.Node{}, false, false, nodePollingPeriod, nil},
+		{k8sresources.NewNamespaceFromRuntime, k8sresources.NamespaceHeader, "namespaces", coreGetter, &corev1.Namespace{}, false, false, namespacePollingPeriod, nil},
 	}
 	watchConfigs := []WatchConfig{}
 	excludedResourcesSet := util.StringSliceToSet(excludedResources)
@@ -145,30 +422,65 @@ func (r *ResourceWatcher) GetWatchConfigs(nodePollingPeriod time.Duration, names
 		if _, ok := excludedResourcesSet[w.resourceName]; ok {
 			continue
 		}
+		derivedConfigs := []WatchConfig{}
+		for _, d := range w.derivedConfigs {
+			if _, ok := excludedResourcesSet[d.resourceName]; ok {
+				continue
+			}
+			derivedConfigs = append(derivedConfigs, d)
+		}
+		w.derivedConfigs = derivedConfigs
 		watchConfigs = append(watchConfigs, w)
 	}
 
 	return watchConfigs
 }
 
-func (r *ResourceWatcher) doPoll(watchlist *cache.ListWatch, k8sStore *K8sStore) {
+// isResourceServed returns true if the apiserver serves the resource in the given group version
+func (r *ResourceWatcher) isResourceServed(groupVersion string, resourceName string) bool {
+	resourceList, err := r.clientset.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		glog.V(4).Infof("Group version %s is not available: %v", groupVersion, err)
+		return false
+	}
+	for _, apiResource := range resourceList.APIResources {
+		if apiResource.Name == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+// doPoll lists the resource and adds it to the store.
+// On error, the store keeps the objects of the last successful poll
+func (r *ResourceWatcher) doPoll(watchlist cache.ListerWatcher, k8sStore *K8sStore) error {
 	obj, err := watchlist.List(metav1.ListOptions{})
 	if err != nil {
-		glog.Warningf("Error on listing %s: %v", k8sStore.resourceName, err)
+		// The failure is recorded by the paged lister
+		return errors.Wrapf(err, "Error on listing %s", k8sStore.resourceName)
 	}
 	lst, err := apimeta.ExtractList(obj)
 	if err != nil {
-		glog.Warningf("Error extracting list %s: %v", k8sStore.resourceName, err)
+		err = errors.Wrapf(err, "Error extracting list %s", k8sStore.resourceName)
+		k8sStore.failed(err)
+		return err
 	}
 	k8sStore.AddResourceList(lst)
+	k8sStore.listDone("")
+	return nil
 }
 
-func (r *ResourceWatcher) FetchNamespaces(ctx context.Context) error {
+// FetchNamespaces lists the namespaces used to split watches and check permissions.
+// On error, namespaced resources are watched across all namespaces, or in the default namespace if they can't be
+func (r *ResourceWatcher) FetchNamespaces(ctx context.Context, defaultNamespace string) error {
+	r.defaultNamespace = defaultNamespace
 	namespaces, err := r.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "Error listing namespaces")
 	}
+	r.namespacesListed = true
 
+	r.namespaces = []string{}
 	for _, namespace := range namespaces.Items {
 		namespaceName := namespace.GetName()
 		if util.IsStringExcluded(namespaceName, r.excludedNamespaces) {
@@ -182,6 +494,12 @@ func (r *ResourceWatcher) FetchNamespaces(ctx context.Context) error {
 
 // DumpAPIResources dumps api resources file
 func (r *ResourceWatcher) DumpAPIResources() error {
+	err := r.dumpAPIResources()
+	r.setStatus("apiresources", resourceAccess{mode: AccessCluster}, err)
+	return err
+}
+
+func (r *ResourceWatcher) dumpAPIResources() error {
 	resourceName := "apiresources"
 	destDir := path.Join(r.storeConfig.CacheDir, r.storeConfig.ClusterDir)
 	err := util.WriteStringToFile(k8sresources.APIResourceHeader, destDir, resourceName, "header")
@@ -190,9 +508,13 @@ func (r *ResourceWatcher) DumpAPIResources() error {
 	}
 
 	var res strings.Builder
-	resourceLists, _ := r.clientset.Discovery().ServerPreferredResources()
+	resourceLists, err := r.clientset.Discovery().ServerPreferredResources()
 	if err != nil {
-		return err
+		if len(resourceLists) == 0 {
+			return errors.Wrapf(err, "Error discovering api resources")
+		}
+		// Unavailable groups are skipped, the other resources are still dumped
+		glog.Warningf("Error discovering some api resources: %v", err)
 	}
 	for _, resourceList := range resourceLists {
 		for _, apiResource := range resourceList.APIResources {
@@ -212,56 +534,123 @@ func (r *ResourceWatcher) DumpAPIResources() error {
 func (r *ResourceWatcher) getWatchList(cfg WatchConfig, k8sStore *K8sStore, namespace string) *cache.ListWatch {
 	optionsModifier := func(options *metav1.ListOptions) {
 		options.FieldSelector = fields.Everything().String()
-		options.ResourceVersion = "0"
+		if !options.Watch && options.Limit == 0 {
+			// Full listings are served from the apiserver's watch cache
+			options.ResourceVersion = "0"
+		}
+	}
+	if r.metadataOnlyResources[cfg.resourceName] {
+		return r.getMetadataWatchList(metadataOnlyCapableResources[cfg.resourceName], namespace, optionsModifier)
 	}
 	watchlist := cache.NewFilteredListWatchFromClient(cfg.getter,
 		k8sStore.resourceName, namespace, optionsModifier)
 	return watchlist
 }
 
+// getMetadataWatchList lists and watches PartialObjectMetadata objects, only metadata are transferred and cached
+func (r *ResourceWatcher) getMetadataWatchList(gvr schema.GroupVersionResource, namespace string,
+	optionsModifier func(options *metav1.ListOptions)) *cache.ListWatch {
+	client := r.metadataClient.Resource(gvr).Namespace(namespace)
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			optionsModifier(&options)
+			return client.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			optionsModifier(&options)
+			return client.Watch(context.Background(), options)
+		},
+	}
+}
+
+// getRuntimeObject returns the type of the objects received by the informer
+func (r *ResourceWatcher) getRuntimeObject(cfg WatchConfig) runtime.Object {
+	if r.metadataOnlyResources[cfg.resourceName] {
+		return &metav1.PartialObjectMetadata{}
+	}
+	return cfg.runtimeObject
+}
+
 func (r *ResourceWatcher) pollResource(ctx context.Context,
 	cfg WatchConfig, k8sStore *K8sStore) {
 	glog.V(4).Infof("Start poller for %s", k8sStore.resourceName)
-	watchlist := r.getWatchList(cfg, k8sStore, "")
+	watchlist := newPagedListerWatcher(r.getWatchList(cfg, k8sStore, ""), k8sStore.resourceName, "",
+		r.listPageSize, nil, k8sStore, ctx.Done())
+
+	r.pollLoop(ctx, watchlist, k8sStore, cfg.pollingPeriod)
+}
 
-	r.doPoll(watchlist, k8sStore)
-	ticker := time.NewTicker(cfg.pollingPeriod)
+// pollBackoff returns the delays between polls after consecutive errors.
+// They start below the polling period and double up to 4 polling periods, with jitter
+// so pollers failing together don't retry together
+func pollBackoff(period time.Duration) wait.Backoff {
+	duration := pollRetryDelay
+	if period < duration {
+		duration = period
+	}
+	return wait.Backoff{Duration: duration, Factor: 2, Jitter: 0.5, Steps: math.MaxInt32, Cap: 4 * period}
+}
+
+// pollLoop polls the resource every period until ctx is done.
+// Errors are retried with the poll backoff, the period is restored after a successful poll
+func (r *ResourceWatcher) pollLoop(ctx context.Context, watchlist cache.ListerWatcher,
+	k8sStore *K8sStore, period time.Duration) {
+	backoff := pollBackoff(period)
 	for {
+		delay := period
+		err := r.doPoll(watchlist, k8sStore)
+		if err != nil {
+			delay = backoff.Step()
+			glog.Warningf("%v, retrying in %s", err, delay)
+		} else {
+			backoff = pollBackoff(period)
+		}
+		timer := time.NewTimer(delay)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			glog.Infof("Exiting poll of %s", k8sStore.resourceName)
 			return
-		case <-ticker.C:
-			r.doPoll(watchlist, k8sStore)
+		case <-timer.C:
 		}
 	}
 }
 
 func (r *ResourceWatcher) startWatch(cfg WatchConfig,
-	k8sStore *K8sStore, namespace string, stop chan struct{}) {
-	watchlist := r.getWatchList(cfg, k8sStore, namespace)
-	_, controller := cache.NewInformer(
-		watchlist, cfg.runtimeObject, time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc:    k8sStore.AddResource,
-			DeleteFunc: k8sStore.DeleteResource,
-			UpdateFunc: k8sStore.UpdateResource,
-		},
-	)
-
-	controller.Run(stop)
-}
-
-func (r *ResourceWatcher) watchResource(ctx context.Context,
-	cfg WatchConfig, k8sStore *K8sStore, namespaces []string) {
-	glog.V(4).Infof("Start watch for %s on namespace %s", k8sStore.resourceName, namespaces)
-
-	stop := make(chan struct{})
-	for _, ns := range namespaces {
-		go r.startWatch(cfg, k8sStore, ns, stop)
+	k8sStore *K8sStore, namespace string, stop <-chan struct{}) {
+	// The reflector feeds a store keeping only the summaries, full objects are not cached.
+	// Fields not needed by the summaries are stripped before the summaries are built
+	var journal *objectJournal
+	if r.storeConfig.ResumeWatches {
+		journal = newObjectJournal(k8sStore.destDir, k8sStore.resourceName, namespace, r.getRuntimeObject(cfg))
+		defer journal.close()
 	}
+	store := newSummaryStore(k8sStore, namespace, k8sresources.StripObject, journal)
+	// Listing pages are added as they arrive so completion is usable before the end of the listing
+	var watchlist cache.ListerWatcher = newPagedListerWatcher(r.getWatchList(cfg, k8sStore, namespace),
+		k8sStore.resourceName, namespace, r.listPageSize, store.addPage, k8sStore, stop)
+	if journal != nil {
+		// The first listing is read from the journal of the previous run and the watch resumes
+		// from its resource version, kept up to date by bookmarks. A 410 Gone triggers a real relist
+		watchlist = newResumeListerWatcher(watchlist, journal)
+	}
+	// Failed listings and watches are restarted by the reflector with an exponential backoff and jitter
+	reflector := cache.NewReflector(watchlist, r.getRuntimeObject(cfg), store, time.Second*0)
+	reflector.Run(stop)
+}
 
-	<-ctx.Done()
-	glog.Infof("Exiting watch of %s namespace %s", k8sStore.resourceName, namespaces)
-	close(stop)
+// watchNamespace starts the watch of a resource in a namespace, stopped with the resource or on its own
+func (r *ResourceWatcher) watchNamespace(res *runningResource, namespace string) {
+	glog.V(4).Infof("Start watch for %s on namespace %s", res.cfg.resourceName, namespace)
+	ctx, cancel := context.WithCancel(res.ctx)
+	w := &namespaceWatch{cancel: cancel, done: make(chan struct{})}
+	res.namespaces[namespace] = w
+	res.wg.Add(1)
+	go func() {
+		defer res.wg.Done()
+		defer close(w.done)
+		r.startWatch(res.cfg, res.store, namespace, ctx.Done())
+		glog.Infof("Exiting watch of %s namespace %s", res.cfg.resourceName, namespace)
+	}()
 }
@@ -0,0 +1,80 @@
+package resourcewatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollBackoff(t *testing.T) {
+	backoff := pollBackoff(time.Minute)
+	first := backoff.Step()
+	assert.GreaterOrEqual(t, first, pollRetryDelay)
+	assert.Less(t, first, 2*pollRetryDelay)
+	for i := 0; i < 10; i++ {
+		backoff.Step()
+	}
+	// The delay is capped to 4 periods plus jitter
+	last := backoff.Step()
+	assert.GreaterOrEqual(t, last, 4*time.Minute)
+	assert.LessOrEqual(t, last, 6*time.Minute)
+
+	// A short polling period is retried sooner
+	backoff = pollBackoff(time.Second)
+	assert.Less(t, backoff.Step(), 2*time.Second)
+}
+
+func TestPollKeepsSnapshotOnError(t *testing.T) {
+	fixture := newStoreFixture(t)
+	ctx, cancel := context.WithCancel(fixture.ctx)
+	defer cancel()
+	cfg := podsWatchConfig()
+	cfg.splitByNamespaces = false
+	cfg.pollingPeriod = time.Hour
+	k := fixture.newStore(cfg, StoreConfig{}, k8sresources.CtorConfig{})
+	lw := &fakeListerWatcher{}
+	lw.setPods(podResource("a", "ns1", nil), podResource("b", "ns1", nil))
+	watchlist := newPagedListerWatcher(lw, "pods", "", 0, nil, k, ctx.Done())
+	r := ResourceWatcher{}
+	assert.Nil(t, r.doPoll(watchlist, k))
+
+	// Failed polls keep the last listed pods
+	lw.failures = []error{errors.New("connection refused"), errors.New("connection refused"), errors.New("timeout")}
+	for i := 0; i < 3; i++ {
+		assert.NotNil(t, r.doPoll(watchlist, k))
+	}
+	assert.Equal(t, []string{"ns1_a", "ns1_b"}, storePodNames(k))
+	status := k.syncStatus()
+	assert.Equal(t, StateError, status.State)
+	assert.Equal(t, 3, status.Failures)
+	assert.Equal(t, "timeout", status.LastError)
+
+	// Failures are retried before the polling period and the count is cleared on recovery
+	previousDelay := pollRetryDelay
+	pollRetryDelay = time.Millisecond
+	defer func() { pollRetryDelay = previousDelay }()
+	lw.mutex.Lock()
+	lw.failures = []error{errors.New("connection refused"), errors.New("connection refused")}
+	lw.mutex.Unlock()
+	lw.setPods(podResource("a", "ns1", nil), podResource("c", "ns1", nil))
+	done := make(chan struct{})
+	go func() {
+		r.pollLoop(ctx, watchlist, k, cfg.pollingPeriod)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		status := k.syncStatus()
+		return status.State == StateSynced && status.Failures == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, storePodNames(k), "ns1_c")
+	lw.mutex.Lock()
+	assert.Len(t, lw.continues, 7)
+	lw.mutex.Unlock()
+	cancel()
+	<-done
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -48,22 +49,38 @@ func (p LabelPairList) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 // K8sStore stores the current state of k8s resources
 type K8sStore struct {
 	data         map[string]k8sresources.K8sResource
+	indexes      map[string]map[string]map[string]bool // Index name -> value -> set of keys
 	labelMap     map[LabelKey]int
 	resourceCtor func(obj interface{}, config k8sresources.CtorConfig) k8sresources.K8sResource
 	ctorConfig   k8sresources.CtorConfig
 	resourceName string
 	currentFile  *os.File
+	relationFile *os.File
 	storeConfig  StoreConfig
 	firstWrite   bool
 	destDir      string
 
-	dataMutex  sync.Mutex
-	labelMutex sync.Mutex
-	fileMutex  sync.Mutex
+	derivedStores []*K8sStore // Stores fed with the same objects
+	lookup        k8sresources.StoreLookup
+
+	dataMutex    sync.Mutex
+	labelMutex   sync.Mutex
+	fileMutex    sync.Mutex
+	resolveMutex sync.Mutex
 
 	labelToDump   bool
+	hasRelations  bool // Relation files are kept up to date once a resource had relations
 	lastFullDump  time.Time
 	lastLabelDump time.Time
+
+	statusMutex sync.Mutex
+	listing     map[string]bool // Namespaces being listed
+	failing     bool            // Set when the last list or watch failed
+	failures    int             // Consecutive list or watch failures
+	lastError   string
+	lastEvent   time.Time
+	lastDump    time.Time // Last successful full dump
+	staleSince  time.Time // Time of the previous snapshot kept on disk until the store is synced
 }
 
 // StoreConfig defines parameters used for the cache location
@@ -71,6 +88,7 @@ type StoreConfig struct {
 	ClusterDir          string
 	CacheDir            string
 	TimeBetweenFullDump time.Duration
+	ResumeWatches       bool // Journal watched objects to resume the watches after a restart
 }
 
 // NewK8sStore creates a new store
@@ -78,6 +96,7 @@ func NewK8sStore(ctx context.Context, cfg WatchConfig, storeConfig StoreConfig,
 	k := K8sStore{}
 	k.destDir = path.Join(storeConfig.CacheDir, storeConfig.ClusterDir)
 	k.data = make(map[string]k8sresources.K8sResource, 0)
+	k.indexes = make(map[string]map[string]map[string]bool, 0)
 	k.labelMap = make(map[LabelKey]int, 0)
 	k.resourceCtor = cfg.resourceCtor
 	k.resourceName = cfg.resourceName
@@ -87,8 +106,14 @@ func NewK8sStore(ctx context.Context, cfg WatchConfig, storeConfig StoreConfig,
 	k.ctorConfig = ctorConfig
 	k.lastLabelDump = time.Time{}
 	k.lastFullDump = time.Time{}
+	k.listing = make(map[string]bool)
+
+	k.keepPreviousSnapshot()
 
 	go k.periodicLabelDump(ctx)
+	if storeConfig.TimeBetweenFullDump > 0 {
+		go k.periodicResolvedDump(ctx)
+	}
 	err := util.WriteStringToFile(cfg.header, k.destDir, k.resourceName, "header")
 	if err != nil {
 		return &k, err
@@ -108,14 +133,226 @@ func resourceKey(obj interface{}) (string, string, map[string]string) {
 		name = o.GetName()
 		labels = o.GetLabels()
 	case *unstructured.Unstructured:
-		metadata := v.Object["metadata"].(map[string]interface{})
-		name = metadata["name"].(string)
-		namespace = metadata["namespace"].(string)
-		labels = metadata["labels"].(map[string]string)
+		namespace = v.GetNamespace()
+		name = v.GetName()
+		labels = v.GetLabels()
 	default:
 		glog.Warningf("Unknown type %v", obj)
 	}
-	return fmt.Sprintf("%s_%s", namespace, name), namespace, labels
+	return k8sresources.ResourceKey(namespace, name), namespace, labels
+}
+
+// keepPreviousSnapshot keeps the resource file of a previous run visible until the store is synced.
+// The snapshot is marked stale so the shell can display it
+func (k *K8sStore) keepPreviousSnapshot() {
+	info, err := os.Stat(path.Join(k.destDir, fmt.Sprintf("%s_%s", k.resourceName, "resource")))
+	if err != nil {
+		return
+	}
+	k.staleSince = info.ModTime()
+	err = util.WriteStringToFile(k.staleSince.Format(time.RFC3339), k.destDir, k.resourceName, "stale")
+	if err != nil {
+		glog.Warningf("Error marking %s as stale: %v", k.resourceName, err)
+	}
+	glog.Infof("Serving previous snapshot of %s from %s until it's synced", k.resourceName, k.staleSince)
+}
+
+func (k *K8sStore) isStale() bool {
+	k.statusMutex.Lock()
+	defer k.statusMutex.Unlock()
+	return !k.staleSince.IsZero()
+}
+
+// swapSnapshot replaces the previous snapshot by the synced state in a single write
+func (k *K8sStore) swapSnapshot() {
+	k.fileMutex.Lock()
+	defer k.fileMutex.Unlock()
+	k.statusMutex.Lock()
+	k.staleSince = time.Time{}
+	k.statusMutex.Unlock()
+	err := k.writeFullState()
+	if err != nil {
+		glog.Warningf("Error when dumping state: %v", err)
+	}
+	err = os.Remove(path.Join(k.destDir, fmt.Sprintf("%s_%s", k.resourceName, "stale")))
+	if err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Error removing stale mark of %s: %v", k.resourceName, err)
+	}
+	glog.Infof("Swapped snapshot of %s", k.resourceName)
+}
+
+// fullDumpPeriod returns the minimum time between two full dumps
+func (k *K8sStore) fullDumpPeriod() time.Duration {
+	k.statusMutex.Lock()
+	defer k.statusMutex.Unlock()
+	return k.storeConfig.TimeBetweenFullDump
+}
+
+// setFullDumpPeriod changes the minimum time between two full dumps of the store and its derived stores
+func (k *K8sStore) setFullDumpPeriod(period time.Duration) {
+	k.statusMutex.Lock()
+	k.storeConfig.TimeBetweenFullDump = period
+	k.statusMutex.Unlock()
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.setFullDumpPeriod(period)
+	}
+}
+
+// removeNamespace drops the objects of a namespace which isn't watched anymore
+func (k *K8sStore) removeNamespace(namespace string) {
+	prefix := k8sresources.ResourceKey(namespace, "")
+	k.dataMutex.Lock()
+	for key, resource := range k.data {
+		if strings.HasPrefix(key, prefix) {
+			k.unindexResource(key, resource)
+			delete(k.data, key)
+		}
+	}
+	k.dataMutex.Unlock()
+	k.labelMutex.Lock()
+	for labelKey := range k.labelMap {
+		if labelKey.Namespace == namespace {
+			delete(k.labelMap, labelKey)
+		}
+	}
+	k.labelToDump = true
+	k.labelMutex.Unlock()
+
+	k.statusMutex.Lock()
+	delete(k.listing, namespace)
+	swap := len(k.listing) == 0 && !k.staleSince.IsZero()
+	k.statusMutex.Unlock()
+	if swap {
+		k.swapSnapshot()
+	} else {
+		err := k.dumpFullState()
+		if err != nil {
+			glog.Warningf("Error when dumping state: %v", err)
+		}
+	}
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.removeNamespace(namespace)
+	}
+}
+
+// closeFiles closes the resource files of the store and its derived stores once they're stopped
+func (k *K8sStore) closeFiles() {
+	k.fileMutex.Lock()
+	for _, file := range []**os.File{&k.currentFile, &k.relationFile} {
+		if *file != nil {
+			(*file).Close()
+			*file = nil
+		}
+	}
+	k.fileMutex.Unlock()
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.closeFiles()
+	}
+}
+
+// removeResourceFiles deletes the cache files and the journals of a resource
+func removeResourceFiles(destDir string, resourceName string) {
+	for _, suffix := range []string{"resource", "header", "label", "relations", "access", "stale"} {
+		err := os.Remove(path.Join(destDir, fmt.Sprintf("%s_%s", resourceName, suffix)))
+		if err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Error removing %s file of %s: %v", suffix, resourceName, err)
+		}
+	}
+	journals, _ := filepath.Glob(path.Join(destDir, journalDir, fmt.Sprintf("%s_*journal", resourceName)))
+	for _, journal := range journals {
+		os.Remove(journal)
+	}
+}
+
+// startListing marks the namespaces which need to be listed before the store is synced
+func (k *K8sStore) startListing(namespaces []string) {
+	k.statusMutex.Lock()
+	for _, namespace := range namespaces {
+		k.listing[namespace] = true
+	}
+	k.statusMutex.Unlock()
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.startListing(namespaces)
+	}
+}
+
+func (k *K8sStore) listStarted(namespace string) {
+	k.startListing([]string{namespace})
+}
+
+// listDone marks the listing of the namespace as complete.
+// The previous snapshot is swapped once all namespaces are listed
+func (k *K8sStore) listDone(namespace string) {
+	k.statusMutex.Lock()
+	delete(k.listing, namespace)
+	k.failing = false
+	k.failures = 0
+	swap := len(k.listing) == 0 && !k.staleSince.IsZero()
+	k.statusMutex.Unlock()
+	if swap {
+		k.swapSnapshot()
+	}
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.listDone(namespace)
+	}
+}
+
+// watchStarted clears the failure of a previous watch
+func (k *K8sStore) watchStarted() {
+	k.statusMutex.Lock()
+	k.failing = false
+	k.failures = 0
+	k.statusMutex.Unlock()
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.watchStarted()
+	}
+}
+
+// failed records a list or watch failure
+func (k *K8sStore) failed(err error) {
+	k.statusMutex.Lock()
+	k.failing = true
+	k.failures++
+	k.lastError = err.Error()
+	k.statusMutex.Unlock()
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.failed(err)
+	}
+}
+
+func (k *K8sStore) touch() {
+	k.statusMutex.Lock()
+	k.lastEvent = time.Now()
+	k.statusMutex.Unlock()
+}
+
+// syncStatus returns the sync state of the store
+func (k *K8sStore) syncStatus() ResourceStatus {
+	k.dataMutex.Lock()
+	objects := len(k.data)
+	k.dataMutex.Unlock()
+	k.statusMutex.Lock()
+	defer k.statusMutex.Unlock()
+	status := ResourceStatus{State: StateSynced, Objects: objects, Failures: k.failures, LastError: k.lastError}
+	if len(k.listing) > 0 {
+		status.State = StateListing
+	}
+	if k.failing {
+		status.State = StateError
+	}
+	if !k.lastEvent.IsZero() {
+		lastEvent := k.lastEvent
+		status.LastEvent = &lastEvent
+	}
+	if !k.lastDump.IsZero() {
+		lastDump := k.lastDump
+		status.LastFullDump = &lastDump
+	}
+	if !k.staleSince.IsZero() {
+		staleSince := k.staleSince
+		status.StaleSince = &staleSince
+	}
+	return status
 }
 
 func (k *K8sStore) periodicLabelDump(ctx context.Context) {
@@ -126,7 +363,7 @@ func (k *K8sStore) periodicLabelDump(ctx context.Context) {
 			return
 		case <-ticker.C:
 			k.labelMutex.Lock()
-			if k.labelToDump {
+			if k.labelToDump && !k.isStale() {
 				k.dumpLabel()
 			}
 			k.labelMutex.Unlock()
@@ -134,6 +371,163 @@ func (k *K8sStore) periodicLabelDump(ctx context.Context) {
 	}
 }
 
+// periodicResolvedDump regularly dumps resources depending on other stores so their
+// computed columns follow changes of the other resources
+func (k *K8sStore) periodicResolvedDump(ctx context.Context) {
+	period := k.fullDumpPeriod()
+	ticker := time.NewTicker(period)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if newPeriod := k.fullDumpPeriod(); newPeriod != period && newPeriod > 0 {
+				period = newPeriod
+				ticker.Reset(period)
+			}
+			if !k.hasResolvableResources() {
+				continue
+			}
+			err := k.DumpFullState()
+			if err != nil {
+				glog.Warningf("Error when dumping state: %v", err)
+			}
+		}
+	}
+}
+
+func (k *K8sStore) hasResolvableResources() bool {
+	if k.lookup == nil {
+		return false
+	}
+	k.dataMutex.Lock()
+	defer k.dataMutex.Unlock()
+	// All resources of a store share the same type, checking one is enough
+	for _, v := range k.data {
+		_, ok := v.(k8sresources.Resolvable)
+		return ok
+	}
+	return false
+}
+
+// GetResource returns the resource stored with the given key
+func (k *K8sStore) GetResource(key string) (k8sresources.K8sResource, bool) {
+	k.dataMutex.Lock()
+	resource, ok := k.data[key]
+	k.dataMutex.Unlock()
+	return resource, ok
+}
+
+// GetIndexedResources returns the resources having the value in the given index
+func (k *K8sStore) GetIndexedResources(indexName string, value string) []k8sresources.K8sResource {
+	k.dataMutex.Lock()
+	defer k.dataMutex.Unlock()
+	keys := k.indexes[indexName][value]
+	res := make([]k8sresources.K8sResource, 0, len(keys))
+	for key := range keys {
+		res = append(res, k.data[key])
+	}
+	return res
+}
+
+// indexResource adds the resource's key to its index values. dataMutex needs to be held
+func (k *K8sStore) indexResource(key string, resource k8sresources.K8sResource) {
+	indexable, ok := resource.(k8sresources.Indexable)
+	if !ok {
+		return
+	}
+	for indexName, values := range indexable.IndexValues() {
+		index, ok := k.indexes[indexName]
+		if !ok {
+			index = make(map[string]map[string]bool)
+			k.indexes[indexName] = index
+		}
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			if _, ok := index[value]; !ok {
+				index[value] = make(map[string]bool)
+			}
+			index[value][key] = true
+		}
+	}
+}
+
+// unindexResource removes the resource's key from its index values. dataMutex needs to be held
+func (k *K8sStore) unindexResource(key string, resource k8sresources.K8sResource) {
+	indexable, ok := resource.(k8sresources.Indexable)
+	if !ok {
+		return
+	}
+	for indexName, values := range indexable.IndexValues() {
+		index := k.indexes[indexName]
+		for _, value := range values {
+			delete(index[value], key)
+			if len(index[value]) == 0 {
+				delete(index, value)
+			}
+		}
+	}
+}
+
+// resourceRecords turns the lines of a resource into records of its key
+func resourceRecords(key string, lines string) string {
+	var res strings.Builder
+	op := util.RecordSet
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line == "" {
+			continue
+		}
+		res.WriteString(fmt.Sprintf("%s %s %s", op, key, line))
+		op = util.RecordAppend
+	}
+	return res.String()
+}
+
+func deleteRecord(key string) string {
+	return fmt.Sprintf("%s %s\n", util.RecordDelete, key)
+}
+
+// relationRecords turns the relations of a resource into records of its key, deleting the key without relations
+func relationRecords(key string, relations string) string {
+	if relations == "" {
+		return deleteRecord(key)
+	}
+	return resourceRecords(key, relations)
+}
+
+// resourceToRecords serializes a resource and its relations as records, computing first
+// the columns depending on other stores. fileMutex needs to be held
+func (k *K8sStore) resourceToRecords(key string, resource k8sresources.K8sResource) (string, string) {
+	str, relationStr := k.resourceToStrings(resource)
+	if _, ok := resource.(k8sresources.Relatable); !ok {
+		return resourceRecords(key, str), ""
+	}
+	k.hasRelations = true
+	return resourceRecords(key, str), relationRecords(key, relationStr)
+}
+
+// deleteRecords returns the tombstones of a deleted resource and of its relations. fileMutex needs to be held
+func (k *K8sStore) deleteRecords(key string) (string, string) {
+	if !k.hasRelations {
+		return deleteRecord(key), ""
+	}
+	return deleteRecord(key), deleteRecord(key)
+}
+
+// resourceToStrings serializes a resource and its relations with the same resolution
+func (k *K8sStore) resourceToStrings(resource k8sresources.K8sResource) (string, string) {
+	resolvable, ok := resource.(k8sresources.Resolvable)
+	if !ok || k.lookup == nil {
+		return resource.ToString(), k8sresources.RelationsToString(resource)
+	}
+	k.resolveMutex.Lock()
+	defer k.resolveMutex.Unlock()
+	resolvable.Resolve(k.lookup)
+	return resource.ToString(), k8sresources.RelationsToString(resource)
+}
+
 func (k *K8sStore) resetLabelMap() {
 	k.labelMutex.Lock()
 	k.labelMap = make(map[LabelKey]int, 0)
@@ -148,42 +542,95 @@ func (k *K8sStore) updateLabelMap(namespace string, labels map[string]string, de
 	k.labelMutex.Unlock()
 }
 
+// hasListChanged returns true if the new resources differ from the stored ones. dataMutex needs to be held
+func (k *K8sStore) hasListChanged(data map[string]k8sresources.K8sResource) bool {
+	if len(data) != len(k.data) {
+		return true
+	}
+	for key, resource := range data {
+		oldResource, ok := k.data[key]
+		if !ok || resource.HasChanged(oldResource) {
+			return true
+		}
+	}
+	return false
+}
+
 // AddResourceList clears current state add the objects to the store.
-// It will trigger a full dump
-// This is used for polled resources, no need for mutex
+// It will trigger a full dump if a resource has changed
+// This is used for polled resources
 func (k *K8sStore) AddResourceList(lstRuntime []runtime.Object) {
-	k.data = make(map[string]k8sresources.K8sResource, 0)
+	k.touch()
+	data := make(map[string]k8sresources.K8sResource, 0)
 	k.resetLabelMap()
 	for _, runtimeObject := range lstRuntime {
 		key, ns, labels := resourceKey(runtimeObject)
 		resource := k.resourceCtor(runtimeObject, k.ctorConfig)
-		k.data[key] = resource
+		if resource == nil {
+			continue
+		}
+		data[key] = resource
 		k.updateLabelMap(ns, labels, 1)
 	}
-	err := k.DumpFullState()
-	if err != nil {
-		glog.Warningf("Error when dumping state: %v", err)
+	k.fileMutex.Lock()
+	dumped := !k.lastFullDump.IsZero()
+	k.fileMutex.Unlock()
+	k.dataMutex.Lock()
+	changed := !dumped || k.hasListChanged(data)
+	k.data = data
+	k.indexes = make(map[string]map[string]map[string]bool, 0)
+	for key, resource := range data {
+		k.indexResource(key, resource)
+	}
+	k.dataMutex.Unlock()
+	if changed {
+		err := k.dumpFullState()
+		if err != nil {
+			glog.Warningf("Error when dumping state: %v", err)
+		}
+	} else {
+		glog.V(8).Infof("No change detected in polled %s", k.resourceName)
+	}
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.AddResourceList(lstRuntime)
 	}
 }
 
 // AddResource adds a new k8s object to the store
 func (k *K8sStore) AddResource(obj interface{}) {
+	k.touch()
 	key, ns, labels := resourceKey(obj)
 	newObj := k.resourceCtor(obj, k.ctorConfig)
+	if newObj == nil {
+		k.forwardAdd(obj)
+		return
+	}
 	glog.V(11).Infof("%s added: %s", k.resourceName, key)
 	k.dataMutex.Lock()
+	if oldObj, ok := k.data[key]; ok {
+		k.unindexResource(key, oldObj)
+	}
 	k.data[key] = newObj
+	k.indexResource(key, newObj)
 	k.dataMutex.Unlock()
 	k.updateLabelMap(ns, labels, 1)
 
-	err := k.AppendNewObject(newObj)
+	err := k.AppendNewObject(key, newObj)
 	if err != nil {
 		glog.Warningf("Error when appending new object to current state: %v", err)
 	}
+	k.forwardAdd(obj)
+}
+
+func (k *K8sStore) forwardAdd(obj interface{}) {
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.AddResource(obj)
+	}
 }
 
 // DeleteResource removes an existing k8s object to the store
 func (k *K8sStore) DeleteResource(obj interface{}) {
+	k.touch()
 	key := "Unknown"
 	ns := "Unknown"
 	var labels map[string]string
@@ -198,12 +645,31 @@ func (k *K8sStore) DeleteResource(obj interface{}) {
 		return
 	}
 	glog.V(11).Infof("%s deleted: %s", k.resourceName, key)
+	k.removeResource(key, ns, labels)
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.DeleteResource(obj)
+	}
+}
+
+// removeResource removes the resource with the given key if present and appends its tombstone
+func (k *K8sStore) removeResource(key string, ns string, labels map[string]string) {
 	k.dataMutex.Lock()
+	oldObj, ok := k.data[key]
+	if ok {
+		k.unindexResource(key, oldObj)
+	}
 	delete(k.data, key)
 	k.dataMutex.Unlock()
+	if !ok {
+		return
+	}
 	k.updateLabelMap(ns, labels, -1)
 
-	err := k.DumpFullState()
+	err := k.appendRecords(func() (string, string) { return k.deleteRecords(key) })
+	if err != nil {
+		glog.Warningf("Error when appending deletion to current state: %v", err)
+	}
+	err = k.DumpFullState()
 	if err != nil {
 		glog.Warningf("Error when dumping state: %v", err)
 	}
@@ -211,49 +677,85 @@ func (k *K8sStore) DeleteResource(obj interface{}) {
 
 // UpdateResource update an existing k8s object
 func (k *K8sStore) UpdateResource(oldObj, newObj interface{}) {
+	k.touch()
 	key, _, _ := resourceKey(newObj)
 	k8sObj := k.resourceCtor(newObj, k.ctorConfig)
+	if k8sObj == nil {
+		// The object is not handled by the store anymore
+		_, ns, labels := resourceKey(oldObj)
+		k.removeResource(key, ns, labels)
+		for _, derivedStore := range k.derivedStores {
+			derivedStore.UpdateResource(oldObj, newObj)
+		}
+		return
+	}
 	k.dataMutex.Lock()
 	if k8sObj.HasChanged(k.data[key]) {
 		glog.V(11).Infof("%s changed: %s", k.resourceName, key)
+		if oldObj, ok := k.data[key]; ok {
+			k.unindexResource(key, oldObj)
+		}
 		k.data[key] = k8sObj
+		k.indexResource(key, k8sObj)
 		k.dataMutex.Unlock()
 		// TODO Handle label diff
 		// k.updateLabelMap(ns, labels, 1)
-		err := k.DumpFullState()
+		err := k.appendRecords(func() (string, string) { return k.resourceToRecords(key, k8sObj) })
+		if err != nil {
+			glog.Warningf("Error when appending update to current state: %v", err)
+		}
+		err = k.DumpFullState()
 		if err != nil {
 			glog.Warningf("Error when dumping state: %v", err)
 		}
 	} else {
 		k.dataMutex.Unlock()
 	}
+	for _, derivedStore := range k.derivedStores {
+		derivedStore.UpdateResource(oldObj, newObj)
+	}
 }
 
-func (k *K8sStore) updateCurrentFile() (err error) {
-	destFile := path.Join(k.destDir, fmt.Sprintf("%s_%s", k.resourceName, "resource"))
-	k.currentFile, err = os.OpenFile(destFile, os.O_APPEND|os.O_WRONLY, 0644)
+// openAppendFile replaces file by a handle appending to the cache file of the suffix
+func (k *K8sStore) openAppendFile(file **os.File, suffix string) (err error) {
+	if *file != nil {
+		(*file).Close()
+	}
+	destFile := path.Join(k.destDir, fmt.Sprintf("%s_%s", k.resourceName, suffix))
+	*file, err = os.OpenFile(destFile, os.O_APPEND|os.O_WRONLY, 0644)
 	return err
 }
 
-// AppendNewObject appends a new object to the cache dump
-func (k *K8sStore) AppendNewObject(resource k8sresources.K8sResource) error {
-	k.fileMutex.Lock()
-	if k.currentFile == nil {
-		var err error
-		err = util.WriteStringToFile(resource.ToString(), k.destDir, k.resourceName, "resource")
-		if err != nil {
-			k.fileMutex.Unlock()
-			return err
-		}
-		err = k.updateCurrentFile()
+// appendToFile appends records to a cache file, writing it on the first append.
+// The previous snapshot is appended to instead until it's swapped. fileMutex needs to be held
+func (k *K8sStore) appendToFile(file **os.File, suffix string, records string) error {
+	if *file == nil && k.isStale() {
+		destFile := path.Join(k.destDir, fmt.Sprintf("%s_%s", k.resourceName, suffix))
+		f, err := os.OpenFile(destFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			k.fileMutex.Unlock()
 			return err
 		}
-		glog.Infof("Initial write of %s", k.currentFile.Name())
+		*file = f
 	}
-	_, err := k.currentFile.WriteString(resource.ToString())
-	k.fileMutex.Unlock()
+	if *file != nil {
+		_, err := (*file).WriteString(records)
+		return err
+	}
+	err := util.WriteStringToFile(records, k.destDir, k.resourceName, suffix)
+	if err != nil {
+		return err
+	}
+	err = k.openAppendFile(file, suffix)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Initial write of %s", (*file).Name())
+	return nil
+}
+
+// AppendNewObject appends a new object to the cache dump
+func (k *K8sStore) AppendNewObject(key string, resource k8sresources.K8sResource) error {
+	err := k.appendRecords(func() (string, string) { return k.resourceToRecords(key, resource) })
 	if err != nil {
 		return err
 	}
@@ -268,6 +770,20 @@ func (k *K8sStore) AppendNewObject(resource k8sresources.K8sResource) error {
 	return nil
 }
 
+// appendRecords appends the records built by recordsFn to the resource and relation files.
+// While the previous snapshot is served, the records update it so a listing which never
+// completes doesn't hold back the watch events. Objects deleted before the restart stay until the swap
+func (k *K8sStore) appendRecords(recordsFn func() (string, string)) error {
+	k.fileMutex.Lock()
+	defer k.fileMutex.Unlock()
+	resourceOutput, relationOutput := recordsFn()
+	err := k.appendToFile(&k.currentFile, "resource", resourceOutput)
+	if err != nil || relationOutput == "" {
+		return err
+	}
+	return k.appendToFile(&k.relationFile, "relations", relationOutput)
+}
+
 func (k *K8sStore) dumpLabel() error {
 	glog.V(8).Infof("Dump of label file %s", k.resourceName)
 	k.lastLabelDump = time.Now()
@@ -313,8 +829,14 @@ func (k *K8sStore) generateLabel() (string, error) {
 }
 
 func (k *K8sStore) generateOutput() (string, error) {
+	output, _, err := k.generateOutputs()
+	return output, err
+}
+
+// generateOutputs serializes all resources as records and their relations.
+// The relation output is empty if the resources have no relations
+func (k *K8sStore) generateOutputs() (string, string, error) {
 	k.dataMutex.Lock()
-	var res strings.Builder
 	keys := make([]string, len(k.data))
 	i := 0
 	for key := range k.data {
@@ -322,32 +844,74 @@ func (k *K8sStore) generateOutput() (string, error) {
 		i = i + 1
 	}
 	sort.Strings(keys)
-	for _, key := range keys {
-		v := k.data[key]
-		_, err := res.WriteString(v.ToString())
+	resources := make([]k8sresources.K8sResource, len(keys))
+	for i, key := range keys {
+		resources[i] = k.data[key]
+	}
+	k.dataMutex.Unlock()
+
+	// Resolution looks up other stores, it's done outside of the data lock
+	var res strings.Builder
+	var relations strings.Builder
+	for i, v := range resources {
+		str, relationStr := k.resourceToStrings(v)
+		str = resourceRecords(keys[i], str)
+		_, err := res.WriteString(str)
 		if err != nil {
-			k.dataMutex.Unlock()
-			return "", errors.Wrapf(err, "Error writing string %s",
-				v.ToString())
+			return "", "", errors.Wrapf(err, "Error writing string %s", str)
+		}
+		relationStr = resourceRecords(keys[i], relationStr)
+		_, err = relations.WriteString(relationStr)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "Error writing string %s", relationStr)
+		}
+		if _, ok := v.(k8sresources.Relatable); ok {
+			k.hasRelations = true
 		}
 	}
-	k.dataMutex.Unlock()
-	return res.String(), nil
+	return res.String(), relations.String(), nil
 }
 
 // DumpFullState writes the full state to the cache file
 func (k *K8sStore) DumpFullState() error {
 	glog.V(8).Infof("Dump full state of %s", k.resourceName)
-	now := time.Now()
-	delta := now.Sub(k.lastFullDump)
-	if delta < k.storeConfig.TimeBetweenFullDump {
+	k.fileMutex.Lock()
+	defer k.fileMutex.Unlock()
+	delta := time.Since(k.lastFullDump)
+	if delta < k.fullDumpPeriod() {
 		glog.V(10).Infof("Last full dump for %s happened %s ago, ignoring it", k.resourceName, delta)
 		return nil
 	}
-	k.lastFullDump = now
-	glog.V(8).Infof("Doing full dump %d %s", len(k.data), k.resourceName)
+	return k.writeSyncedState()
+}
+
+// dumpFullState writes the full state to the cache file without throttling, compacting its records.
+// Nothing is written while the previous snapshot is served
+func (k *K8sStore) dumpFullState() error {
+	k.fileMutex.Lock()
+	defer k.fileMutex.Unlock()
+	return k.writeSyncedState()
+}
+
+// writeSyncedState writes the full state unless the previous snapshot is served. fileMutex needs to be held
+func (k *K8sStore) writeSyncedState() error {
+	if k.isStale() {
+		glog.V(10).Infof("Keeping previous snapshot of %s until it's synced", k.resourceName)
+		return nil
+	}
+	return k.writeFullState()
+}
 
-	resourceOutput, err := k.generateOutput()
+// writeFullState replaces the cache files by the current state.
+// Appends are blocked until the new file is opened so none is lost. fileMutex needs to be held
+func (k *K8sStore) writeFullState() error {
+	k.lastFullDump = time.Now()
+	k.dataMutex.Lock()
+	objects := len(k.data)
+	k.dataMutex.Unlock()
+	glog.V(8).Infof("Doing full dump %d %s", objects, k.resourceName)
+
+	resourceOutput, relationOutput, err := k.generateOutputs()
 	if err != nil {
 		return errors.Wrapf(err, "Error generating output")
 	}
@@ -355,14 +919,30 @@ func (k *K8sStore) DumpFullState() error {
 	if err != nil {
 		return err
 	}
-	err = k.updateCurrentFile()
+	err = k.openAppendFile(&k.currentFile, "resource")
 	if err != nil {
 		return err
 	}
+	if k.hasRelations {
+		err = util.WriteStringToFile(relationOutput, k.destDir, k.resourceName, "relations")
+		if err != nil {
+			return err
+		}
+		err = k.openAppendFile(&k.relationFile, "relations")
+		if err != nil {
+			return err
+		}
+	}
 	labelOutput, err := k.generateLabel()
 	if err != nil {
 		return errors.Wrapf(err, "Error generating label output")
 	}
 	err = util.WriteStringToFile(labelOutput, k.destDir, k.resourceName, "label")
-	return err
+	if err != nil {
+		return err
+	}
+	k.statusMutex.Lock()
+	k.lastDump = time.Now()
+	k.statusMutex.Unlock()
+	return nil
 }
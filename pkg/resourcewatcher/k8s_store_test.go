@@ -2,7 +2,12 @@ package resourcewatcher
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -11,8 +16,10 @@ import (
 	"time"
 
 	"kubectlfzf/pkg/k8sresources"
+	"kubectlfzf/pkg/util"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -38,7 +45,7 @@ func getK8sStore(t *testing.T) (string, *K8sStore) {
 	defer os.RemoveAll(tempDir)
 
 	cfg := WatchConfig{
-		k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), nil, &corev1.Pod{}, true, true, 0,
+		k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), nil, &corev1.Pod{}, true, true, 0, nil,
 	}
 	storeConfig := StoreConfig{
 		CacheDir: tempDir,
@@ -59,7 +66,50 @@ func getK8sStore(t *testing.T) (string, *K8sStore) {
 	for _, pod := range pods {
 		k.AddResource(&pod)
 	}
-	return tempDir, &k
+	return tempDir, k
+}
+
+// storeFixture holds the temp dir, context and lookup shared by the stores of a test
+type storeFixture struct {
+	t       *testing.T
+	ctx     context.Context
+	tempDir string
+	lookup  *storeLookup
+}
+
+// newStoreFixture creates a temp dir and a context, both cleaned up at the end of the test
+func newStoreFixture(t *testing.T) *storeFixture {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		os.RemoveAll(tempDir)
+	})
+	return &storeFixture{t: t, ctx: ctx, tempDir: tempDir, lookup: &storeLookup{stores: make(map[string]*K8sStore)}}
+}
+
+// newStore creates a store writing in the fixture's temp dir
+func (f *storeFixture) newStore(cfg WatchConfig, storeConfig StoreConfig, ctorConfig k8sresources.CtorConfig) *K8sStore {
+	storeConfig.CacheDir = f.tempDir
+	k, err := NewK8sStore(f.ctx, cfg, storeConfig, ctorConfig)
+	assert.Nil(f.t, err)
+	return k
+}
+
+// link registers the stores in the fixture's lookup and resolves their resources with it
+func (f *storeFixture) link(stores ...*K8sStore) {
+	for _, store := range stores {
+		store.lookup = f.lookup
+		f.lookup.addStore(store)
+	}
+}
+
+// podsWatchConfig returns the config of pods watched per namespace, without getter
+func podsWatchConfig() WatchConfig {
+	return WatchConfig{
+		k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), nil, &corev1.Pod{}, true, true, 0, nil,
+	}
 }
 
 func TestDumpFullState(t *testing.T) {
@@ -92,3 +142,250 @@ func TestSortedPairList(t *testing.T) {
 	assert.Contains(t, split[1], "app3")
 	assert.Contains(t, split[2], "app1")
 }
+
+func TestResolveController(t *testing.T) {
+	fixture := newStoreFixture(t)
+	rsCfg := WatchConfig{
+		k8sresources.NewReplicaSetFromRuntime, k8sresources.ReplicaSetHeader, "replicasets", nil, &appsv1.ReplicaSet{}, true, false, 0, nil,
+	}
+	podStore := fixture.newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{})
+	rsStore := fixture.newStore(rsCfg, StoreConfig{}, k8sresources.CtorConfig{})
+	fixture.link(podStore, rsStore)
+
+	isController := true
+	rs := appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "app-1234", Namespace: "ns1",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "app", Controller: &isController}},
+		},
+		Spec: appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{}},
+	}
+	rsStore.AddResource(&rs)
+	pod := podResource("app-1234-abcd", "ns1", nil)
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "app-1234", Controller: &isController}}
+	podStore.AddResource(&pod)
+
+	output, err := podStore.generateOutput()
+	assert.Nil(t, err)
+	fields := strings.Split(output, " ")
+	assert.Contains(t, fields, "ReplicaSet/app-1234")
+	assert.Contains(t, fields, "Deployment/app")
+}
+
+func TestUsedBy(t *testing.T) {
+	fixture := newStoreFixture(t)
+	secretCfg := WatchConfig{
+		k8sresources.NewSecretFromRuntime, k8sresources.SecretHeader, string(corev1.ResourceSecrets), nil, &corev1.Secret{}, true, false, 0, nil,
+	}
+	podStore := fixture.newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{})
+	secretStore := fixture.newStore(secretCfg, StoreConfig{}, k8sresources.CtorConfig{})
+	fixture.link(secretStore)
+
+	secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "ns1"}}
+	secretStore.AddResource(&secret)
+	output, err := secretStore.generateOutput()
+	assert.Nil(t, err)
+	assert.Contains(t, strings.Split(output, " "), "Unknown")
+
+	fixture.link(podStore)
+	pod := podResource("pod1", "ns1", nil)
+	pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	podStore.AddResource(&pod)
+	otherPod := podResource("pod2", "ns2", nil)
+	otherPod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	podStore.AddResource(&otherPod)
+
+	output, err = secretStore.generateOutput()
+	assert.Nil(t, err)
+	assert.Contains(t, strings.Split(output, " "), "pod1")
+	assert.NotContains(t, output, "pod2")
+
+	podStore.DeleteResource(&pod)
+	output, err = secretStore.generateOutput()
+	assert.Nil(t, err)
+	assert.NotContains(t, output, "pod1")
+}
+
+func TestServiceRelations(t *testing.T) {
+	fixture := newStoreFixture(t)
+	serviceCfg := WatchConfig{
+		k8sresources.NewServiceFromRuntime, k8sresources.ServiceHeader, string(corev1.ResourceServices), nil, &corev1.Service{}, true, false, 0, nil,
+	}
+	podStore := fixture.newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{Cluster: "test"})
+	serviceStore := fixture.newStore(serviceCfg, StoreConfig{}, k8sresources.CtorConfig{Cluster: "test"})
+	fixture.link(podStore, serviceStore)
+
+	readyPod := podResource("web-1", "ns1", map[string]string{"app": "web"})
+	readyPod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	podStore.AddResource(&readyPod)
+	notReadyPod := podResource("web-2", "ns1", map[string]string{"app": "web"})
+	podStore.AddResource(&notReadyPod)
+	otherPod := podResource("db-1", "ns1", map[string]string{"app": "db"})
+	podStore.AddResource(&otherPod)
+
+	service := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns1"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	serviceStore.AddResource(&service)
+
+	output, relations, err := serviceStore.generateOutputs()
+	assert.Nil(t, err)
+	assert.Contains(t, strings.Split(output, " "), "1/2")
+	assert.Equal(t, "= ns1_web test ns1 web selects pods ns1 web-1 Ready\n"+
+		"+ ns1_web test ns1 web selects pods ns1 web-2 NotReady\n", relations)
+
+	err = serviceStore.dumpFullState()
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(path.Join(fixture.tempDir, "services_relations"))
+	assert.Nil(t, err)
+	assert.Equal(t, relations, string(content))
+
+	// Deletions are appended to the relation file without waiting for the next full dump
+	serviceStore.DeleteResource(&service)
+	f, err := os.Open(path.Join(fixture.tempDir, "services_relations"))
+	assert.Nil(t, err)
+	defer f.Close()
+	lines, err := util.ReadRecordLines(f)
+	assert.Nil(t, err)
+	assert.Empty(t, lines)
+}
+
+func helmReleaseSecret(t *testing.T, name string, revision int, status string) corev1.Secret {
+	payload := fmt.Sprintf(`{"name":"%s","namespace":"ns1","version":%d,"info":{"status":"%s"},`+
+		`"chart":{"metadata":{"name":"nginx","version":"1.2.3","appVersion":"1.21"}},`+
+		`"config":{"password":"hunter2"},"manifest":"kind: Secret\\ndata:\\n  token: c2VjcmV0"}`, name, revision, status)
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(payload))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Namespace: "ns1",
+			Labels:    map[string]string{"name": name, "owner": "helm"},
+		},
+		Type: k8sresources.HelmReleaseSecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(b.Bytes()))},
+	}
+}
+
+func TestHelmReleases(t *testing.T) {
+	fixture := newStoreFixture(t)
+	cfg := WatchConfig{
+		k8sresources.NewHelmReleaseFromRuntime, k8sresources.HelmReleaseHeader, "helmreleases", nil, &corev1.Secret{}, true, false, 0, nil,
+	}
+	store := fixture.newStore(cfg, StoreConfig{}, k8sresources.CtorConfig{})
+
+	userSecret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "password", Namespace: "ns1"}}
+	store.AddResource(&userSecret)
+	previous := helmReleaseSecret(t, "web", 1, "superseded")
+	store.AddResource(&previous)
+	current := helmReleaseSecret(t, "web", 2, "deployed")
+	store.AddResource(&current)
+
+	output, err := store.generateOutput()
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"ns1", "web", "nginx", "1.2.3", "1.21", "1", "superseded"}, strings.Split(lines[0], " ")[3:10])
+	assert.Equal(t, []string{"ns1", "web", "nginx", "1.2.3", "1.21", "2", "deployed"}, strings.Split(lines[1], " ")[3:10])
+}
+
+func TestWarmStart(t *testing.T) {
+	fixture := newStoreFixture(t)
+	resourceFile := path.Join(fixture.tempDir, "pods_resource")
+	staleFile := path.Join(fixture.tempDir, "pods_stale")
+	err := ioutil.WriteFile(resourceFile, []byte("= ns1_Test0 previous snapshot\n= ns1_Test1 previous Test1\n"), 0644)
+	assert.Nil(t, err)
+
+	k := fixture.newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{})
+	assert.FileExists(t, staleFile)
+	assert.NotNil(t, k.syncStatus().StaleSince)
+
+	// The previous snapshot is served during the listing, without being replaced by a full dump
+	k.startListing([]string{"ns1", "ns2"})
+	pod := podResource("Test1", "ns1", nil)
+	k.AddResource(&pod)
+	k.listDone("ns1")
+	err = k.DumpFullState()
+	assert.Nil(t, err)
+	readLines := func() []string {
+		f, err := os.Open(resourceFile)
+		assert.Nil(t, err)
+		defer f.Close()
+		lines, err := util.ReadRecordLines(f)
+		assert.Nil(t, err)
+		return lines
+	}
+	lines := readLines()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "previous snapshot", lines[0])
+
+	// Events update it while a namespace isn't listed
+	k.failed(errors.New("connection refused"))
+	updated := podResource("Test1", "ns1", nil)
+	updated.Spec.NodeName = "node2"
+	k.UpdateResource(&pod, &updated)
+	lines = readLines()
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "node2")
+	assert.NotNil(t, k.syncStatus().StaleSince)
+
+	// And swapped once every namespace is listed
+	k.listDone("ns2")
+	content, err := ioutil.ReadFile(resourceFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "Test1")
+	assert.NotContains(t, string(content), "previous")
+	assert.NoFileExists(t, staleFile)
+	assert.Nil(t, k.syncStatus().StaleSince)
+
+	// New objects are appended after the swap
+	pod = podResource("Test2", "ns2", nil)
+	k.AddResource(&pod)
+	content, err = ioutil.ReadFile(resourceFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "Test2")
+}
+
+func TestResourceRecords(t *testing.T) {
+	fixture := newStoreFixture(t)
+	resourceFile := path.Join(fixture.tempDir, "pods_resource")
+	k := fixture.newStore(podsWatchConfig(), StoreConfig{TimeBetweenFullDump: time.Hour}, k8sresources.CtorConfig{})
+	a := podResource("a", "ns1", nil)
+	k.AddResource(&a)
+	b := podResource("b", "ns1", nil)
+	k.AddResource(&b)
+	assert.Nil(t, k.dumpFullState())
+
+	// Updates and deletions are appended right away, without waiting for the next full dump
+	updated := podResource("a", "ns1", nil)
+	updated.Spec.NodeName = "node2"
+	k.UpdateResource(&a, &updated)
+	k.DeleteResource(&b)
+	content, err := ioutil.ReadFile(resourceFile)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, []string{"=", "ns1_a"}, strings.Split(lines[0], " ")[:2])
+	assert.Equal(t, []string{"=", "ns1_b"}, strings.Split(lines[1], " ")[:2])
+	assert.Equal(t, []string{"=", "ns1_a"}, strings.Split(lines[2], " ")[:2])
+	assert.Contains(t, lines[2], "node2")
+	assert.Equal(t, "- ns1_b", lines[3])
+
+	// A full dump compacts the records
+	assert.Nil(t, k.dumpFullState())
+	content, err = ioutil.ReadFile(resourceFile)
+	assert.Nil(t, err)
+	lines = strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "node2")
+}
+
+func TestResourceRecordsSeveralLines(t *testing.T) {
+	assert.Equal(t, "= ns1_a c1 ok\n+ ns1_a c2 ok\n", resourceRecords("ns1_a", "c1 ok\nc2 ok\n"))
+	assert.Equal(t, "", resourceRecords("ns1_a", ""))
+	assert.Equal(t, "- ns1_a\n", deleteRecord("ns1_a"))
+}
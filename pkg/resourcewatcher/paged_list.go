@@ -0,0 +1,149 @@
+package resourcewatcher
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxPageRetries is the number of attempts to fetch a page before giving up the listing
+const maxPageRetries = 5
+
+// pageBackoff is the delay between attempts to fetch a page
+var pageBackoff = wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: maxPageRetries}
+
+// syncTracker follows the progress of the listings and watches of a resource
+type syncTracker interface {
+	listStarted(namespace string)
+	watchStarted()
+	failed(err error)
+}
+
+// pagedListerWatcher lists a resource by pages using continue tokens.
+// Each page is passed to onPage as it arrives so partial results are available before the end of the listing.
+// A failed page is retried from its continue token, an expired token restarts the listing
+type pagedListerWatcher struct {
+	cache.ListerWatcher
+	resourceName string
+	namespace    string
+	pageSize     int64
+	onPage       func(items []runtime.Object) error
+	tracker      syncTracker // Optional
+	stop         <-chan struct{}
+	backoff      wait.Backoff
+}
+
+func newPagedListerWatcher(lw cache.ListerWatcher, resourceName string, namespace string, pageSize int64,
+	onPage func(items []runtime.Object) error, tracker syncTracker, stop <-chan struct{}) *pagedListerWatcher {
+	return &pagedListerWatcher{
+		ListerWatcher: lw,
+		resourceName:  resourceName,
+		namespace:     namespace,
+		pageSize:      pageSize,
+		onPage:        onPage,
+		tracker:       tracker,
+		stop:          stop,
+		backoff:       pageBackoff,
+	}
+}
+
+// List fetches all pages and returns them as a single list
+func (p *pagedListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	if p.tracker != nil {
+		p.tracker.listStarted(p.namespace)
+	}
+	list, err := p.list(options)
+	if err != nil && p.tracker != nil {
+		p.tracker.failed(err)
+	}
+	return list, err
+}
+
+// Watch starts a watch, reporting failures to the tracker
+func (p *pagedListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := p.ListerWatcher.Watch(options)
+	if p.tracker != nil {
+		if err != nil {
+			p.tracker.failed(errors.Wrapf(err, "Error watching %s", p.resourceName))
+		} else {
+			p.tracker.watchStarted()
+		}
+	}
+	return w, err
+}
+
+func (p *pagedListerWatcher) list(options metav1.ListOptions) (runtime.Object, error) {
+	if p.pageSize <= 0 {
+		return p.ListerWatcher.List(options)
+	}
+	// Pages are only served by consistent reads, the watch cache ignores the limit
+	options.ResourceVersion = ""
+	options.ResourceVersionMatch = ""
+	options.Limit = p.pageSize
+	options.Continue = ""
+	items := make([]runtime.Object, 0)
+	backoff := p.backoff
+	for {
+		page, err := p.ListerWatcher.List(options)
+		if err != nil {
+			if options.Continue != "" && apierrors.IsResourceExpired(err) {
+				glog.Warningf("Continue token of %s expired after %d objects, restarting the listing", p.resourceName, len(items))
+				options.Continue = ""
+				items = make([]runtime.Object, 0)
+				backoff = p.backoff
+				continue
+			}
+			if backoff.Steps <= 1 {
+				return nil, errors.Wrapf(err, "Error listing %s after %d attempts", p.resourceName, p.backoff.Steps)
+			}
+			delay := backoff.Step()
+			glog.Warningf("Error listing %s after %d objects, resuming in %s: %v", p.resourceName, len(items), delay, err)
+			select {
+			case <-p.stop:
+				return nil, errors.Errorf("Listing of %s stopped", p.resourceName)
+			case <-time.After(delay):
+			}
+			continue
+		}
+		backoff = p.backoff
+
+		pageItems, err := apimeta.ExtractList(page)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error extracting page of %s", p.resourceName)
+		}
+		select {
+		case <-p.stop:
+			// The watch was stopped during the request, its objects aren't wanted anymore
+			return nil, errors.Errorf("Listing of %s stopped", p.resourceName)
+		default:
+		}
+		if p.onPage != nil {
+			err = p.onPage(pageItems)
+			if err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, pageItems...)
+		listMeta, err := apimeta.ListAccessor(page)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading page metadata of %s", p.resourceName)
+		}
+		if listMeta.GetContinue() == "" {
+			glog.V(4).Infof("Listed %d %s", len(items), p.resourceName)
+			err = apimeta.SetList(page, items)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error building list of %s", p.resourceName)
+			}
+			return page, nil
+		}
+		options.Continue = listMeta.GetContinue()
+	}
+}
@@ -0,0 +1,58 @@
+package resourcewatcher
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestPagedList(t *testing.T) {
+	lw := &fakeListerWatcher{}
+	pods := make([]corev1.Pod, 5)
+	for i := range pods {
+		pods[i] = podResource(fmt.Sprintf("pod%d", i), "ns1", nil)
+	}
+	lw.setPods(pods...)
+	pageSizes := []int{}
+	p := newPagedListerWatcher(lw, "pods", "", 2, func(items []runtime.Object) error {
+		pageSizes = append(pageSizes, len(items))
+		if len(pageSizes) == 2 {
+			// The third page fails once, then its token expires
+			lw.failures = []error{errors.New("connection reset"), apierrors.NewResourceExpired("continue expired")}
+		}
+		return nil
+	}, nil, make(chan struct{}))
+	p.backoff = wait.Backoff{Duration: time.Millisecond, Steps: maxPageRetries}
+
+	list, err := p.List(metav1.ListOptions{ResourceVersion: "0"})
+	assert.Nil(t, err)
+	items, err := apimeta.ExtractList(list)
+	assert.Nil(t, err)
+	assert.Len(t, items, 5)
+	assert.Equal(t, "", list.(*corev1.PodList).Continue)
+	// Pages are passed as they arrive, the listing restarts after the expired token
+	assert.Equal(t, []int{2, 2, 2, 2, 1}, pageSizes)
+	assert.Equal(t, []string{"", "2", "4", "4", "", "2", "4"}, lw.continues)
+}
+
+func TestPagedListGivesUp(t *testing.T) {
+	lw := &fakeListerWatcher{}
+	lw.setPods(podResource("pod", "ns1", nil))
+	for i := 0; i < maxPageRetries; i++ {
+		lw.failures = append(lw.failures, errors.New("connection refused"))
+	}
+	p := newPagedListerWatcher(lw, "pods", "", 2, nil, nil, make(chan struct{}))
+	p.backoff = wait.Backoff{Duration: time.Millisecond, Steps: maxPageRetries}
+	_, err := p.List(metav1.ListOptions{})
+	assert.NotNil(t, err)
+	assert.Len(t, lw.continues, maxPageRetries)
+}
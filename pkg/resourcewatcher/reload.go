@@ -0,0 +1,197 @@
+package resourcewatcher
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"regexp"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+	"kubectlfzf/pkg/util"
+
+	"github.com/golang/glog"
+)
+
+// Reconfigure applies a changed configuration without restarting the resources it doesn't affect.
+// Excluded resources are stopped and their cache files removed, included resources are started.
+// Resources watched by namespace stop and start the watches of excluded and included namespaces,
+// resources watched in their accessible namespaces check them again on an exclusion change.
+// Resources whose polling period or derived resources changed are restarted, as well as polled
+// resources on a ctor config change: the role blacklist only applies to the polled nodes
+func (r *ResourceWatcher) Reconfigure(ctx context.Context, watchConfigs []WatchConfig, ctorConfig k8sresources.CtorConfig,
+	excludedNamespaces []string, timeBetweenFullDump time.Duration) error {
+	excluded, err := compileExcludedNamespaces(excludedNamespaces)
+	if err != nil {
+		return err
+	}
+	namespacesChanged := !sameExcludedNamespaces(r.excludedNamespaces, excludedNamespaces)
+	if namespacesChanged {
+		glog.Infof("%d Namespaces will be excluded: %s", len(excludedNamespaces), excludedNamespaces)
+		r.excludedNamespaces = excluded
+		r.refreshNamespaces(ctx)
+	}
+
+	if timeBetweenFullDump != r.storeConfig.TimeBetweenFullDump {
+		glog.Infof("Time between full dumps changed from %s to %s", r.storeConfig.TimeBetweenFullDump, timeBetweenFullDump)
+		r.storeConfig.TimeBetweenFullDump = timeBetweenFullDump
+		for _, res := range r.started {
+			if res.store != nil {
+				res.store.setFullDumpPeriod(timeBetweenFullDump)
+			}
+		}
+	}
+
+	wanted := make(map[string]bool, len(watchConfigs))
+	for _, cfg := range watchConfigs {
+		wanted[cfg.resourceName] = true
+	}
+	for resourceName, res := range r.started {
+		if !wanted[resourceName] {
+			glog.Infof("Stopping excluded resource %s", resourceName)
+			r.stopResource(res, true)
+		}
+	}
+
+	for _, cfg := range watchConfigs {
+		res, ok := r.started[cfg.resourceName]
+		switch {
+		case !ok:
+			glog.Infof("Starting included resource %s", cfg.resourceName)
+		case res.cfg.pollingPeriod != cfg.pollingPeriod,
+			!util.StringSlicesEqual(derivedResourceNames(res.cfg), derivedResourceNames(cfg)),
+			cfg.pollingPeriod > 0 && !reflect.DeepEqual(res.ctorConfig, ctorConfig):
+			glog.Infof("Restarting %s with its new configuration", cfg.resourceName)
+			r.stopResource(res, false)
+			r.removeExcludedDerived(res, cfg)
+		case namespacesChanged && res.access.mode == AccessForbidden && cfg.hasNamespace:
+			glog.Infof("Restarting %s to check the access to the included namespaces", cfg.resourceName)
+			r.stopResource(res, false)
+		case namespacesChanged && res.access.mode == AccessNamespaces && !r.updateAccessNamespaces(ctx, res):
+			glog.Infof("Restarting %s, none of its namespaces can be watched anymore", cfg.resourceName)
+			r.stopResource(res, false)
+		default:
+			if res.split {
+				r.updateNamespaces(res, r.namespaces)
+			}
+			continue
+		}
+		err := r.Start(ctx, cfg, ctorConfig)
+		if err != nil {
+			glog.Warningf("Resource degraded: %v", err)
+		}
+	}
+	return nil
+}
+
+// sameExcludedNamespaces returns true if the compiled exclusions match the given patterns
+func sameExcludedNamespaces(current []*regexp.Regexp, excludedNamespaces []string) bool {
+	if len(current) != len(excludedNamespaces) {
+		return false
+	}
+	for i, rg := range current {
+		if rg.String() != excludedNamespaces[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshNamespaces lists the namespaces again with the current exclusions.
+// If they can't be listed, the excluded ones are only removed from the known namespaces
+func (r *ResourceWatcher) refreshNamespaces(ctx context.Context) {
+	if !r.namespacesListed {
+		return
+	}
+	err := r.FetchNamespaces(ctx, r.defaultNamespace)
+	if err == nil {
+		return
+	}
+	glog.Warningf("Newly included namespaces won't be watched: %v", err)
+	r.namespaces = util.FilterSliceWithRegexps(r.namespaces, r.excludedNamespaces)
+}
+
+// updateAccessNamespaces checks the accessible namespaces of a resource with the current exclusions
+// and updates its watches. It returns false if the resource isn't watched by namespace anymore
+func (r *ResourceWatcher) updateAccessNamespaces(ctx context.Context, res *runningResource) bool {
+	access := r.checkAccess(ctx, res.cfg)
+	if access.mode != AccessNamespaces {
+		return false
+	}
+	res.access = access
+	r.updateNamespaces(res, access.namespaces)
+	r.setStatuses(res.cfg, access, nil)
+	return true
+}
+
+// updateNamespaces stops the watches of the namespaces which aren't watched anymore
+// and drops their objects, then starts the watches of the new namespaces
+func (r *ResourceWatcher) updateNamespaces(res *runningResource, watched []string) {
+	namespaces := util.StringSliceToSet(watched)
+	for namespace, w := range res.namespaces {
+		if _, ok := namespaces[namespace]; ok {
+			continue
+		}
+		glog.Infof("Stopping watch of %s in excluded namespace %s", res.cfg.resourceName, namespace)
+		w.stop()
+		delete(res.namespaces, namespace)
+		res.store.removeNamespace(namespace)
+	}
+	added := []string{}
+	for _, namespace := range watched {
+		if _, ok := res.namespaces[namespace]; !ok {
+			added = append(added, namespace)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	glog.Infof("Starting watch of %s in included namespaces %v", res.cfg.resourceName, added)
+	res.store.startListing(added)
+	for _, namespace := range added {
+		r.watchNamespace(res, namespace)
+	}
+}
+
+// stopResource stops the watches or the poll of a resource and waits for them to exit.
+// If the resource is excluded, its stores, status and cache files are removed
+func (r *ResourceWatcher) stopResource(res *runningResource, excluded bool) {
+	delete(r.started, res.cfg.resourceName)
+	if res.cancel != nil {
+		res.cancel()
+		res.wg.Wait()
+	}
+	if res.store != nil {
+		res.store.closeFiles()
+	}
+	if excluded {
+		r.removeResources(append([]string{res.cfg.resourceName}, derivedResourceNames(res.cfg)...))
+	}
+}
+
+// removeExcludedDerived removes the derived resources of a restarted resource which are now excluded
+func (r *ResourceWatcher) removeExcludedDerived(res *runningResource, cfg WatchConfig) {
+	wanted := util.StringSliceToSet(derivedResourceNames(cfg))
+	resourceNames := []string{}
+	for _, resourceName := range derivedResourceNames(res.cfg) {
+		if !wanted[resourceName] {
+			resourceNames = append(resourceNames, resourceName)
+		}
+	}
+	r.removeResources(resourceNames)
+}
+
+// removeResources drops the stores, the status and the cache files of stopped resources
+func (r *ResourceWatcher) removeResources(resourceNames []string) {
+	if len(resourceNames) == 0 {
+		return
+	}
+	r.stores.removeStores(resourceNames)
+	for _, resourceName := range resourceNames {
+		removeResourceFiles(path.Join(r.storeConfig.CacheDir, r.storeConfig.ClusterDir), resourceName)
+	}
+	err := r.status.Remove(resourceNames)
+	if err != nil {
+		glog.Warningf("Error writing status: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package resourcewatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+// fakeAPIServer serves the pods of each namespace and the nodes. Watches never send events
+type fakeAPIServer struct {
+	pods  map[string][]corev1.Pod
+	nodes []corev1.Node
+	lists map[string]int // Number of listings of each path
+	mutex sync.Mutex
+}
+
+// Get creates a request with its own fake client, fake clients can't be shared by concurrent requests
+func (f *fakeAPIServer) Get() *restclient.Request {
+	client := &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         corev1.SchemeGroupVersion,
+		Client:               restfake.CreateHTTPClient(f.serve),
+	}
+	return client.Get()
+}
+
+func (f *fakeAPIServer) serve(req *http.Request) (*http.Response, error) {
+	header := http.Header{"Content-Type": []string{runtime.ContentTypeJSON}}
+	if req.URL.Query().Get("watch") == "true" {
+		// The body is closed when the watch is stopped
+		reader, _ := io.Pipe()
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: reader}, nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.lists[req.URL.Path]++
+	listMeta := metav1.ListMeta{ResourceVersion: "1"}
+	var list runtime.Object
+	if req.URL.Path == "/nodes" {
+		list = &corev1.NodeList{TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"}, ListMeta: listMeta, Items: f.nodes}
+	} else {
+		namespace := strings.Split(req.URL.Path, "/")[2]
+		list = &corev1.PodList{TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"}, ListMeta: listMeta, Items: f.pods[namespace]}
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (f *fakeAPIServer) listCount(path string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.lists[path]
+}
+
+func namespaceObject(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestReconfigure(t *testing.T) {
+	fixture := newStoreFixture(t)
+	ctx, tempDir := fixture.ctx, fixture.tempDir
+
+	server := &fakeAPIServer{
+		pods: map[string][]corev1.Pod{
+			"ns1": {podResource("a", "ns1", nil)},
+			"ns2": {podResource("b", "ns2", nil)},
+			"ns3": {podResource("c", "ns3", nil)},
+		},
+		nodes: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}},
+		lists: make(map[string]int),
+	}
+	excludedNamespaces, err := compileExcludedNamespaces([]string{"ns3"})
+	assert.Nil(t, err)
+	storeConfig := StoreConfig{CacheDir: tempDir, ClusterDir: "test", TimeBetweenFullDump: time.Minute}
+	r := ResourceWatcher{
+		clientset:          fake.NewSimpleClientset(namespaceObject("ns1"), namespaceObject("ns2"), namespaceObject("ns3")),
+		excludedNamespaces: excludedNamespaces,
+		storeConfig:        storeConfig,
+		stores:             &storeLookup{stores: make(map[string]*K8sStore)},
+		started:            make(map[string]*runningResource),
+		status:             NewClusterStatus("test", path.Join(tempDir, "test")),
+	}
+	assert.Nil(t, r.FetchNamespaces(ctx, ""))
+	podsCfg := WatchConfig{
+		k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), server, &corev1.Pod{}, true, true, 0, nil,
+	}
+	nodesCfg := WatchConfig{
+		k8sresources.NewNodeFromRuntime, k8sresources.NodeHeader, "nodes", server, &corev1.Node{}, false, false, time.Hour, nil,
+	}
+	ctorConfig := k8sresources.CtorConfig{}
+	for _, cfg := range []WatchConfig{podsCfg, nodesCfg} {
+		assert.Nil(t, r.Start(ctx, cfg, ctorConfig))
+	}
+	assert.Eventually(t, func() bool {
+		names := storePodNames(r.started["pods"].store)
+		return len(names) == 2 && names[1] == "ns2_b"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// ns2 is excluded and ns3 included, ns1 keeps its watch
+	assert.Nil(t, r.Reconfigure(ctx, []WatchConfig{podsCfg, nodesCfg}, ctorConfig, []string{"ns2"}, 2*time.Minute))
+	podStore := r.started["pods"].store
+	assert.Eventually(t, func() bool {
+		names := storePodNames(podStore)
+		return len(names) == 2 && names[1] == "ns3_c"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"ns1", "ns3"}, r.namespaces)
+	assert.Equal(t, 1, server.listCount("/namespaces/ns1/pods"))
+	assert.Equal(t, 2*time.Minute, podStore.fullDumpPeriod())
+	assert.Equal(t, 1, server.listCount("/nodes"))
+
+	// A changed role blacklist restarts the node poll, the pods are excluded
+	ctorConfig = k8sresources.CtorConfig{RoleBlacklist: map[string]bool{"compute": true}}
+	assert.Nil(t, r.Reconfigure(ctx, []WatchConfig{nodesCfg}, ctorConfig, []string{"ns2"}, 2*time.Minute))
+	assert.Eventually(t, func() bool {
+		return server.listCount("/nodes") == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, server.listCount("/namespaces/ns1/pods"))
+	_, err = os.Stat(path.Join(tempDir, "test", "pods_resource"))
+	assert.True(t, os.IsNotExist(err))
+	_, ok := r.stores.GetResource("pods", "ns1_a")
+	assert.False(t, ok)
+	r.status.mutex.Lock()
+	_, ok = r.status.Resources["pods"]
+	r.status.mutex.Unlock()
+	assert.False(t, ok)
+	assert.NotContains(t, r.started, "pods")
+	r.Stop()
+}
+
+func TestReconfigureAccessNamespaces(t *testing.T) {
+	fixture := newStoreFixture(t)
+	ctx, tempDir := fixture.ctx, fixture.tempDir
+
+	server := &fakeAPIServer{
+		pods: map[string][]corev1.Pod{
+			"ns1": {podResource("a", "ns1", nil)},
+			"ns2": {podResource("b", "ns2", nil)},
+		},
+		lists: make(map[string]int),
+	}
+	r := ResourceWatcher{
+		clientset:     fake.NewSimpleClientset(namespaceObject("ns1"), namespaceObject("ns2"), namespaceObject("ns3")),
+		authorization: fakeAccessReviews(map[string]bool{"pods/ns1": true, "pods/ns2": true}).AuthorizationV1(),
+		accessCache:   newAccessCache(),
+		storeConfig:   StoreConfig{CacheDir: tempDir, ClusterDir: "test", TimeBetweenFullDump: time.Minute},
+		stores:        &storeLookup{stores: make(map[string]*K8sStore)},
+		started:       make(map[string]*runningResource),
+		status:        NewClusterStatus("test", path.Join(tempDir, "test")),
+	}
+	assert.Nil(t, r.FetchNamespaces(ctx, ""))
+	podsCfg := WatchConfig{
+		k8sresources.NewPodFromRuntime, k8sresources.PodHeader, string(corev1.ResourcePods), server, &corev1.Pod{}, true, true, 0, nil,
+	}
+	ctorConfig := k8sresources.CtorConfig{}
+	assert.Nil(t, r.Start(ctx, podsCfg, ctorConfig))
+	assert.Eventually(t, func() bool {
+		return len(storePodNames(r.started["pods"].store)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	accessFile := path.Join(tempDir, "test", "pods_access")
+
+	// ns2 is excluded, ns1 keeps its watch
+	assert.Nil(t, r.Reconfigure(ctx, []WatchConfig{podsCfg}, ctorConfig, []string{"ns2"}, time.Minute))
+	assert.Equal(t, []string{"ns1_a"}, storePodNames(r.started["pods"].store))
+	assert.Equal(t, []string{"ns1"}, r.started["pods"].access.namespaces)
+	assert.Equal(t, 1, server.listCount("/namespaces/ns1/pods"))
+	b, err := ioutil.ReadFile(accessFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "namespaces ns1", string(b))
+
+	// No accessible namespace is left, the resource is skipped
+	assert.Nil(t, r.Reconfigure(ctx, []WatchConfig{podsCfg}, ctorConfig, []string{"ns1", "ns2"}, time.Minute))
+	assert.Nil(t, r.started["pods"].store)
+	b, err = ioutil.ReadFile(accessFile)
+	assert.Nil(t, err)
+	assert.Equal(t, AccessForbidden, string(b))
+
+	// The skipped resource is watched again once its namespaces are included
+	assert.Nil(t, r.Reconfigure(ctx, []WatchConfig{podsCfg}, ctorConfig, nil, time.Minute))
+	assert.Equal(t, AccessNamespaces, r.started["pods"].access.mode)
+	assert.Eventually(t, func() bool {
+		return len(storePodNames(r.started["pods"].store)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	r.Stop()
+}
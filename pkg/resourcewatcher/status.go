@@ -0,0 +1,179 @@
+package resourcewatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"kubectlfzf/pkg/util"
+
+	"github.com/pkg/errors"
+)
+
+// Resource states recorded in the status file
+const (
+	StateListing   = "listing"   // The initial listing or a relist is in progress
+	StateSynced    = "synced"    // The cache follows the watch events
+	StateError     = "error"     // The resource failed to start or its last list/watch failed
+	StateForbidden = "forbidden" // The resource can't be listed, it's skipped
+)
+
+// statusDumpPeriod is the period between writes of the status file
+const statusDumpPeriod = 5 * time.Second
+
+// StatusFileName is the name of the status file in the cluster cache dir
+const StatusFileName = "cluster_status"
+
+// ResourceStatus is the state of the cache of a resource
+type ResourceStatus struct {
+	State        string     `json:"state"`
+	Access       string     `json:"access,omitempty"`
+	Namespaces   []string   `json:"namespaces,omitempty"` // Watched namespaces when not watched cluster-wide
+	Objects      int        `json:"objects"`
+	LastEvent    *time.Time `json:"lastEvent,omitempty"`
+	LastFullDump *time.Time `json:"lastFullDump,omitempty"`
+	StaleSince   *time.Time `json:"staleSince,omitempty"` // Set while the snapshot of a previous run is served
+	Failures     int        `json:"failures,omitempty"`   // Consecutive list, watch or poll failures
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// ClusterStatus is the state of the caches of a cluster, written in the cluster_status file
+type ClusterStatus struct {
+	Cluster   string                     `json:"cluster"`
+	Updated   time.Time                  `json:"updated"`
+	Resources map[string]*ResourceStatus `json:"resources"`
+
+	destDir string
+	mutex   sync.Mutex
+}
+
+// NewClusterStatus creates an empty status written in destDir
+func NewClusterStatus(cluster string, destDir string) *ClusterStatus {
+	return &ClusterStatus{
+		Cluster:   cluster,
+		Resources: make(map[string]*ResourceStatus),
+		destDir:   destDir,
+	}
+}
+
+// ReadClusterStatus reads the status file of a cluster cache dir
+func ReadClusterStatus(clusterDir string) (*ClusterStatus, error) {
+	b, err := ioutil.ReadFile(path.Join(clusterDir, StatusFileName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading status of %s, is cache_builder running?", clusterDir)
+	}
+	status := &ClusterStatus{}
+	err = json.Unmarshal(b, status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing status of %s", clusterDir)
+	}
+	return status, nil
+}
+
+// SetListing records that the resource is being listed, cluster-wide or in the given namespaces
+func (s *ClusterStatus) SetListing(resourceName string, access string, namespaces []string) error {
+	return s.set(resourceName, &ResourceStatus{State: StateListing, Access: access, Namespaces: namespaces})
+}
+
+// SetForbidden records that the resource is skipped as it can't be listed
+func (s *ClusterStatus) SetForbidden(resourceName string) error {
+	return s.set(resourceName, &ResourceStatus{State: StateForbidden, Access: AccessForbidden})
+}
+
+// SetError records that the resource couldn't be watched and why
+func (s *ClusterStatus) SetError(resourceName string, err error) error {
+	return s.set(resourceName, &ResourceStatus{State: StateError, LastError: err.Error()})
+}
+
+func (s *ClusterStatus) set(resourceName string, status *ResourceStatus) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Resources[resourceName] = status
+	return s.write()
+}
+
+// Remove drops the resources which aren't watched anymore
+func (s *ClusterStatus) Remove(resourceNames []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, resourceName := range resourceNames {
+		delete(s.Resources, resourceName)
+	}
+	return s.write()
+}
+
+// update refreshes the sync state of a resource, keeping its access
+func (s *ClusterStatus) update(resourceName string, status ResourceStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if current, ok := s.Resources[resourceName]; ok {
+		status.Access = current.Access
+		status.Namespaces = current.Namespaces
+	}
+	s.Resources[resourceName] = &status
+}
+
+// Write dumps the status file
+func (s *ClusterStatus) Write() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write()
+}
+
+// write dumps the status file. mutex needs to be held
+func (s *ClusterStatus) write() error {
+	s.Updated = time.Now()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "Error serializing status")
+	}
+	return util.WriteStringToFile(string(b), s.destDir, "cluster", "status")
+}
+
+// sinceString formats the time elapsed since t
+func sinceString(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%s ago", time.Since(*t).Round(time.Second))
+}
+
+// WriteTable prints the status as a table
+func (s *ClusterStatus) WriteTable(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Cluster %s, status updated %s\n\n", s.Cluster, sinceString(&s.Updated))
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s.Resources))
+	for name := range s.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tSTATE\tACCESS\tOBJECTS\tLAST EVENT\tLAST DUMP\tFAILURES\tLAST ERROR")
+	for _, name := range names {
+		r := s.Resources[name]
+		access := r.Access
+		if len(r.Namespaces) > 0 {
+			access = fmt.Sprintf("%s:%s", r.Access, strings.Join(r.Namespaces, ","))
+		}
+		lastError := r.LastError
+		if lastError == "" {
+			lastError = "-"
+		}
+		state := r.State
+		if r.StaleSince != nil {
+			state = fmt.Sprintf("%s(stale)", r.State)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%d\t%s\n", name, state, access, r.Objects,
+			sinceString(r.LastEvent), sinceString(r.LastFullDump), r.Failures, lastError)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+package resourcewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStartDegraded(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	// The cache dir is a file so stores can't write their headers
+	cacheFile := path.Join(tempDir, "file")
+	assert.Nil(t, ioutil.WriteFile(cacheFile, []byte{}, 0644))
+
+	r := ResourceWatcher{
+		storeConfig: StoreConfig{CacheDir: cacheFile, ClusterDir: "test"},
+		stores:      &storeLookup{stores: make(map[string]*K8sStore)},
+		started:     make(map[string]*runningResource),
+		status:      NewClusterStatus("test", tempDir),
+	}
+	cfg := WatchConfig{
+		k8sresources.NewSecretFromRuntime, k8sresources.SecretHeader, "secrets", nil, &corev1.Secret{}, true, false, 0, []WatchConfig{
+			{k8sresources.NewHelmReleaseFromRuntime, k8sresources.HelmReleaseHeader, "helmreleases", nil, &corev1.Secret{}, true, false, 0, nil},
+		},
+	}
+	err = r.Start(context.Background(), cfg, k8sresources.CtorConfig{})
+	assert.NotNil(t, err)
+
+	b, err := ioutil.ReadFile(path.Join(tempDir, "cluster_status"))
+	assert.Nil(t, err)
+	status := ClusterStatus{}
+	assert.Nil(t, json.Unmarshal(b, &status))
+	assert.Equal(t, "test", status.Cluster)
+	assert.Equal(t, StateError, status.Resources["secrets"].State)
+	assert.Equal(t, StateError, status.Resources["helmreleases"].State)
+	assert.Contains(t, status.Resources["secrets"].LastError, "Error starting secrets")
+}
+
+func TestStatusTable(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp/", "cacheTest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	status := NewClusterStatus("test", tempDir)
+	assert.Nil(t, status.SetListing("pods", AccessNamespaces, []string{"ns1", "ns2"}))
+	assert.Nil(t, status.SetForbidden("nodes"))
+	lastEvent := time.Now()
+	status.update("pods", ResourceStatus{State: StateSynced, Objects: 12, LastEvent: &lastEvent})
+	status.update("nodes", ResourceStatus{State: StateError, Objects: 3, Failures: 2, LastError: "timeout"})
+	status.update("services", ResourceStatus{State: StateListing, StaleSince: &lastEvent})
+	assert.Nil(t, status.Write())
+
+	read, err := ReadClusterStatus(tempDir)
+	assert.Nil(t, err)
+	var b strings.Builder
+	assert.Nil(t, read.WriteTable(&b))
+	lines := strings.Split(b.String(), "\n")
+	assert.Equal(t, "Cluster test, status updated 0s ago", lines[0])
+	assert.Equal(t, []string{"RESOURCE", "STATE", "ACCESS", "OBJECTS", "LAST", "EVENT", "LAST", "DUMP", "FAILURES", "LAST", "ERROR"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"nodes", "error", "forbidden", "3", "-", "-", "2", "timeout"}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"pods", "synced", "namespaces:ns1,ns2", "12", "0s", "ago", "-", "0", "-"}, strings.Fields(lines[4]))
+	assert.Equal(t, []string{"services", "listing(stale)", "0", "-", "-", "0", "-"}, strings.Fields(lines[5]))
+}
@@ -0,0 +1,234 @@
+package resourcewatcher
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// summaryStore is the store fed by a reflector in place of an informer's indexer.
+// Events are forwarded to the K8sStore which only keeps the summaries of the objects.
+// Only the metadata needed to detect deletions on relist is kept here.
+// Stripped objects are written to the journal to resume the watch after a restart
+type summaryStore struct {
+	k8sStore  *K8sStore
+	namespace string
+	transform cache.TransformFunc
+	journal   *objectJournal // Optional
+	known     map[string]*metav1.PartialObjectMetadata
+	mutex     sync.Mutex
+}
+
+var _ cache.Store = &summaryStore{}
+var _ cache.ResourceVersionUpdater = &summaryStore{}
+
+func newSummaryStore(k8sStore *K8sStore, namespace string, transform cache.TransformFunc, journal *objectJournal) *summaryStore {
+	return &summaryStore{
+		k8sStore:  k8sStore,
+		namespace: namespace,
+		transform: transform,
+		journal:   journal,
+		known:     make(map[string]*metav1.PartialObjectMetadata),
+	}
+}
+
+// metadataOf returns the metadata kept for a known object
+func metadataOf(obj interface{}) *metav1.PartialObjectMetadata {
+	accessor, ok := obj.(metav1.ObjectMetaAccessor)
+	if !ok {
+		return nil
+	}
+	o := accessor.GetObjectMeta()
+	return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+		Name:            o.GetName(),
+		Namespace:       o.GetNamespace(),
+		Labels:          o.GetLabels(),
+		ResourceVersion: o.GetResourceVersion(),
+	}}
+}
+
+func (s *summaryStore) transformObject(obj interface{}) (interface{}, error) {
+	if s.transform == nil {
+		return obj, nil
+	}
+	return s.transform(obj)
+}
+
+// upsert forwards an add or an update depending on whether the object is already known
+// and returns the transformed object. mutex needs to be held
+func (s *summaryStore) upsert(obj interface{}) (interface{}, error) {
+	obj, err := s.transformObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _ := resourceKey(obj)
+	oldObj, ok := s.known[key]
+	s.known[key] = metadataOf(obj)
+	if ok {
+		s.k8sStore.UpdateResource(oldObj, obj)
+	} else {
+		s.k8sStore.AddResource(obj)
+	}
+	return obj, nil
+}
+
+// upsertEvent forwards an object received by the watch and journals it. mutex needs to be held
+func (s *summaryStore) upsertEvent(obj interface{}) error {
+	obj, err := s.upsert(obj)
+	if err != nil {
+		return err
+	}
+	if s.journal != nil {
+		s.journal.addObject(obj, len(s.known))
+	}
+	return nil
+}
+
+// Add forwards a new object to the K8sStore
+func (s *summaryStore) Add(obj interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.upsertEvent(obj)
+}
+
+// Update forwards a modified object to the K8sStore
+func (s *summaryStore) Update(obj interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.upsertEvent(obj)
+}
+
+// addPage forwards the objects of a listing page before the end of the listing.
+// Objects missing from the full listing are deleted by Replace
+func (s *summaryStore) addPage(items []runtime.Object) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, obj := range items {
+		_, err := s.upsert(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete forwards a deleted object to the K8sStore
+func (s *summaryStore) Delete(obj interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var key string
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		key = d.Key
+	} else {
+		key, _, _ = resourceKey(obj)
+	}
+	delete(s.known, key)
+	s.k8sStore.DeleteResource(obj)
+	if s.journal != nil {
+		s.journal.deleteObject(key, obj, len(s.known))
+	}
+	return nil
+}
+
+// isForwarded returns true if the object was already forwarded at the same resource version,
+// like the objects of the listing pages. mutex needs to be held
+func (s *summaryStore) isForwarded(key string, obj interface{}) bool {
+	known, ok := s.known[key]
+	return ok && known != nil && known.ResourceVersion != "" && known.ResourceVersion == objectResourceVersion(obj)
+}
+
+// Replace handles a relist like an informer: listed objects are added or updated
+// and known objects missing from the list are deleted. The journal is replaced by the listed objects.
+// Objects forwarded by addPage are not converted again
+func (s *summaryStore) Replace(list []interface{}, resourceVersion string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	listed := make(map[string]bool, len(list))
+	objects := make([]interface{}, 0, len(list))
+	for _, obj := range list {
+		key, _, _ := resourceKey(obj)
+		listed[key] = true
+		var err error
+		if s.isForwarded(key, obj) {
+			if s.journal == nil {
+				continue
+			}
+			obj, err = s.transformObject(obj)
+		} else {
+			obj, err = s.upsert(obj)
+		}
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+	for key, oldObj := range s.known {
+		if listed[key] {
+			continue
+		}
+		glog.V(11).Infof("%s missing from relist: %s", s.k8sStore.resourceName, key)
+		delete(s.known, key)
+		s.k8sStore.DeleteResource(cache.DeletedFinalStateUnknown{Key: key, Obj: oldObj})
+	}
+	if s.journal != nil {
+		s.journal.writeSnapshot(objects, resourceVersion)
+	}
+	s.k8sStore.listDone(s.namespace)
+	return nil
+}
+
+// UpdateResourceVersion journals the resource version of the last event or bookmark
+func (s *summaryStore) UpdateResourceVersion(resourceVersion string) {
+	if s.journal != nil {
+		s.journal.setResourceVersion(resourceVersion)
+	}
+}
+
+// Resync dumps the current summaries. Objects are not kept so they can't be redelivered
+func (s *summaryStore) Resync() error {
+	return s.k8sStore.DumpFullState()
+}
+
+// List returns the metadata of the known objects
+func (s *summaryStore) List() []interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make([]interface{}, 0, len(s.known))
+	for _, obj := range s.known {
+		res = append(res, obj)
+	}
+	return res
+}
+
+// ListKeys returns the keys of the known objects
+func (s *summaryStore) ListKeys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make([]string, 0, len(s.known))
+	for key := range s.known {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Get returns the metadata of a known object
+func (s *summaryStore) Get(obj interface{}) (interface{}, bool, error) {
+	key, _, _ := resourceKey(obj)
+	return s.GetByKey(key)
+}
+
+// GetByKey returns the metadata of the object with the given key
+func (s *summaryStore) GetByKey(key string) (interface{}, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	obj, ok := s.known[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return obj, true, nil
+}
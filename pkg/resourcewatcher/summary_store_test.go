@@ -0,0 +1,166 @@
+package resourcewatcher
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubectlfzf/pkg/k8sresources"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeListerWatcher serves a mutable pod list by pages and hands out fake watchers to the test.
+// Queued failures are returned by the next list calls
+type fakeListerWatcher struct {
+	pods      []corev1.Pod
+	failures  []error
+	continues []string // Continue tokens of the list calls
+	versions  []string // Resource versions of the watch calls
+	mutex     sync.Mutex
+	watchers  chan *watch.FakeWatcher
+}
+
+func (f *fakeListerWatcher) setPods(pods ...corev1.Pod) {
+	f.mutex.Lock()
+	f.pods = pods
+	f.mutex.Unlock()
+}
+
+func (f *fakeListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.continues = append(f.continues, options.Continue)
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return nil, err
+	}
+	start, end := 0, len(f.pods)
+	if options.Continue != "" {
+		start, _ = strconv.Atoi(options.Continue)
+	}
+	if options.Limit > 0 && start+int(options.Limit) < end {
+		end = start + int(options.Limit)
+	}
+	list := &corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: make([]corev1.Pod, end-start)}
+	copy(list.Items, f.pods[start:end])
+	if end < len(f.pods) {
+		list.Continue = strconv.Itoa(end)
+	}
+	return list, nil
+}
+
+func (f *fakeListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	f.mutex.Lock()
+	f.versions = append(f.versions, options.ResourceVersion)
+	f.mutex.Unlock()
+	w := watch.NewFake()
+	f.watchers <- w
+	return w, nil
+}
+
+func storePodNames(k *K8sStore) []string {
+	k.dataMutex.Lock()
+	defer k.dataMutex.Unlock()
+	names := make([]string, 0, len(k.data))
+	for key := range k.data {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSummaryStoreReflector(t *testing.T) {
+	k := newStoreFixture(t).newStore(podsWatchConfig(), StoreConfig{}, k8sresources.CtorConfig{})
+
+	lw := &fakeListerWatcher{watchers: make(chan *watch.FakeWatcher, 1)}
+	lw.setPods(podResource("a", "ns1", map[string]string{"app": "v1"}), podResource("b", "ns1", nil))
+	store := newSummaryStore(k, "", k8sresources.StripObject, nil)
+	k.startListing([]string{""})
+	assert.Equal(t, StateListing, k.syncStatus().State)
+	reflector := cache.NewReflector(lw, &corev1.Pod{}, store, 0)
+	stop := make(chan struct{})
+	defer close(stop)
+	go reflector.Run(stop)
+
+	// Initial list
+	w := <-lw.watchers
+	assert.Equal(t, []string{"ns1_a", "ns1_b"}, storePodNames(k))
+	assert.Equal(t, []string{"ns1_a", "ns1_b"}, store.ListKeys())
+	status := k.syncStatus()
+	assert.Equal(t, StateSynced, status.State)
+	assert.Equal(t, 2, status.Objects)
+	assert.NotNil(t, status.LastEvent)
+
+	// Watch events
+	c := podResource("c", "ns1", nil)
+	w.Add(&c)
+	a := podResource("a", "ns1", map[string]string{"app": "v2"})
+	a.Spec.NodeName = "node2"
+	w.Modify(&a)
+	b := podResource("b", "ns1", nil)
+	w.Delete(&b)
+	assert.Eventually(t, func() bool {
+		names := storePodNames(k)
+		return len(names) == 2 && names[0] == "ns1_a" && names[1] == "ns1_c"
+	}, 5*time.Second, 10*time.Millisecond)
+	// The reflector may still be dumping the store
+	k.fileMutex.Lock()
+	output, err := k.generateOutput()
+	k.fileMutex.Unlock()
+	assert.Nil(t, err)
+	assert.Contains(t, strings.Split(output, "\n")[0], "node2")
+
+	// An expired watch triggers a relist, objects missing from it are deleted
+	lw.setPods(podResource("a", "ns1", map[string]string{"app": "v2"}), podResource("d", "ns1", nil))
+	w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired})
+	<-lw.watchers
+	assert.Equal(t, []string{"ns1_a", "ns1_d"}, storePodNames(k))
+	assert.Equal(t, []string{"ns1_a", "ns1_d"}, store.ListKeys())
+	obj, ok, err := store.GetByKey("ns1_a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", obj.(*metav1.PartialObjectMetadata).Labels["app"])
+}
+
+func TestSummaryStoreReplacePages(t *testing.T) {
+	fixture := newStoreFixture(t)
+	converted := make([]string, 0)
+	cfg := podsWatchConfig()
+	cfg.resourceCtor = func(obj interface{}, config k8sresources.CtorConfig) k8sresources.K8sResource {
+		converted = append(converted, obj.(*corev1.Pod).Name)
+		return k8sresources.NewPodFromRuntime(obj, config)
+	}
+	k := fixture.newStore(cfg, StoreConfig{}, k8sresources.CtorConfig{})
+	journal := newObjectJournal(fixture.tempDir, "pods", "", &corev1.Pod{})
+	defer journal.close()
+	store := newSummaryStore(k, "", k8sresources.StripObject, journal)
+
+	k.startListing([]string{""})
+	assert.Nil(t, store.addPage([]runtime.Object{podWithVersion("a", "1"), podWithVersion("b", "1")}))
+	assert.Equal(t, []string{"a", "b"}, converted)
+
+	// Only the objects missing from the pages or changed since are converted again
+	converted = converted[:0]
+	list := []interface{}{podWithVersion("a", "1"), podWithVersion("b", "2"), podWithVersion("c", "2")}
+	assert.Nil(t, store.Replace(list, "2"))
+	assert.Equal(t, []string{"b", "c"}, converted)
+	assert.Equal(t, []string{"ns1_a", "ns1_b", "ns1_c"}, storePodNames(k))
+
+	// The journal holds every listed object
+	snapshot, err := journal.load()
+	assert.Nil(t, err)
+	items, err := apimeta.ExtractList(snapshot)
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+}
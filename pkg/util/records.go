@@ -0,0 +1,54 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Records of the resource and relation files. These files are logs compacted by full dumps,
+// the query side keeps the last lines of each key
+const (
+	RecordSet    = "=" // First line of a key, replacing its previous lines
+	RecordAppend = "+" // Following line of a key, for resources with several lines
+	RecordDelete = "-" // Tombstone of a deleted key
+)
+
+// ReadRecordLines returns the current lines of a record file in the order their keys appeared.
+// Lines without record are returned as is
+func ReadRecordLines(r io.Reader) ([]string, error) {
+	order := []string{}
+	lines := make(map[string][]string)
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Text()
+		fields := strings.SplitN(line, " ", 3)
+		// Keys have no spaces, lines without record can't be mistaken for one
+		key := " " + strconv.Itoa(i)
+		switch {
+		case len(fields) == 3 && fields[0] == RecordSet:
+			key = fields[1]
+			lines[key] = []string{fields[2]}
+		case len(fields) == 3 && fields[0] == RecordAppend:
+			key = fields[1]
+			lines[key] = append(lines[key], fields[2])
+		case len(fields) == 2 && fields[0] == RecordDelete:
+			delete(lines, fields[1])
+			continue
+		default:
+			lines[key] = []string{line}
+		}
+		if !seen[key] {
+			seen[key] = true
+			order = append(order, key)
+		}
+	}
+	res := []string{}
+	for _, key := range order {
+		res = append(res, lines[key]...)
+	}
+	return res, scanner.Err()
+}
@@ -7,7 +7,7 @@ import (
 	"os"
 	"path"
 	"regexp"
-	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -36,19 +36,33 @@ func StringSliceToSet(lst []string) map[string]bool {
 	return res
 }
 
+// SetToSortedSlice converts a set like map to a sorted string slice
+func SetToSortedSlice(set map[string]bool) []string {
+	res := make([]string, 0, len(set))
+	for el := range set {
+		res = append(res, el)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // GetDestFileName builds the destination filename
-func GetDestFileName(cacheDir string, cluster string, resourceName string) string {
+func GetDestFileName(cacheDir string, cluster string, resourceName string) (string, error) {
 	destDir := path.Join(cacheDir, cluster)
 	destFileName := path.Join(destDir, resourceName)
 	err := os.MkdirAll(destDir, os.ModePerm)
-	FatalIf(err)
-	return destFileName
+	if err != nil {
+		return "", errors.Wrapf(err, "Error creating cache dir %s", destDir)
+	}
+	return destFileName, nil
 }
 
 // WriteStringToFile writes string to the given file and sync file
 func WriteStringToFile(str string, destDir string, resourceName string, suffix string) error {
 	err := os.MkdirAll(destDir, os.ModePerm)
-	FatalIf(err)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating cache dir %s", destDir)
+	}
 	name := fmt.Sprintf("%s_%s", resourceName, suffix)
 	tempPattern := fmt.Sprintf("_%s_%s", resourceName, suffix)
 	glog.V(6).Infof("Writing file %s", name)
@@ -110,7 +124,8 @@ func JoinSlicesWithMaxOrNone(sl []string, max int, sep string) string {
 	if len(sl) < max {
 		return strings.Join(sl, sep)
 	}
-	toDisplay := sl[:max]
+	toDisplay := make([]string, max, max+1)
+	copy(toDisplay, sl)
 	toDisplay = append(toDisplay, "...")
 	return strings.Join(toDisplay, sep)
 }
@@ -191,15 +206,6 @@ func FilterSliceWithRegexps(sl []string, excludeRegexps []*regexp.Regexp) []stri
 	return res[:i]
 }
 
-// FatalIf exits if the error is not nil
-func FatalIf(err error) {
-	if err != nil {
-		debug.PrintStack()
-		fmt.Printf("Fatal error: %s\n", err)
-		os.Exit(-1)
-	}
-}
-
 // JoinIntSlice creates a string of joined int with a separator character
 func JoinIntSlice(a []int, sep string) string {
 	if len(a) == 0 {